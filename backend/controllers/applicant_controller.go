@@ -1,19 +1,22 @@
 package controllers
 
 import (
-	"bytes"
 	"context"
-	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
+	"math"
 	"net/http"
+	"sort"
 	"time"
 
 	"backend/db"
 	"backend/elo"
 	"backend/models"
+	"backend/pairing"
 
+	"github.com/go-chi/chi/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
@@ -21,37 +24,141 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// longest Idempotency-Key header accepted on votes
+const maxIdempotencyKeyLength = 255
+
 type ApplicantController struct {
 	collection *mongo.Collection
+	matches    *mongo.Collection
 }
 
 func NewApplicantController() *ApplicantController {
 	return &ApplicantController{
 		collection: db.GetCollection("applicants"),
+		matches:    db.GetCollection("matches"),
 	}
 }
 
 // elo and comparison helper functions
 
-func contains(matchesPlayed []primitive.ObjectID, id primitive.ObjectID) bool {
-	for _, matchID := range matchesPlayed {
-		if matchID == id {
-			return true
-		}
+// pairPools caches each project's pairing pool between requests
+var pairPools = pairing.NewCache(loadPairPool)
+
+// loadPairPool reads only what pairing needs, never the applicants' files
+func loadPairPool(ctx context.Context, projectID primitive.ObjectID) (*pairing.Pool, error) {
+	project := lookupProject(ctx, projectID)
+
+	opts := options.Find().SetProjection(bson.M{
+		"_id": 1, "elo": 1, "rating_deviation": 1, "wins": 1, "losses": 1, "draws": 1,
+	})
+	cursor, err := db.GetCollection("applicants").Find(ctx, bson.M{"project_id": projectID}, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var docs []models.Applicant
+	if err = cursor.All(ctx, &docs); err != nil {
+		return nil, err
+	}
+
+	applicants := make([]pairing.Applicant, 0, len(docs))
+	for _, doc := range docs {
+		applicants = append(applicants, pairing.Applicant{ID: doc.ID, Elo: doc.Elo, Deviation: pairingDeviation(doc)})
+	}
+
+	// who has judged which pair comes from the votes cast since history was last reset
+	filter := bson.M{"project_id": projectID}
+	if project.HistoryResetAt != nil {
+		filter["created_at"] = bson.M{"$gt": *project.HistoryResetAt}
+	}
+	matchOpts := options.Find().SetProjection(bson.M{"winner_id": 1, "loser_id": 1, "outcome": 1, "reviewer_id": 1})
+	cursor, err = db.GetCollection("matches").Find(ctx, filter, matchOpts)
+	if err != nil {
+		return nil, err
+	}
+	var matches []models.Match
+	if err = cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+
+	reviews := make([]pairing.Review, 0, len(matches))
+	for _, match := range matches {
+		reviews = append(reviews, matchReview(match))
+	}
+	pool := pairing.NewPool(project.PairingStrategy, project.ReviewsPerPair, applicants, reviews)
+
+	// pairs leased before the reload stay with their reviewers
+	leases, err := activeLeases(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	for _, lease := range leases {
+		pool.Reserve(lease.ApplicantA, lease.ApplicantB, lease.ExpiresAt)
+	}
+	return pool, nil
+}
+
+// pairingDeviation is how uncertain an applicant's rating is: the tracked deviation on
+// systems like Glicko-2, otherwise an estimate that shrinks with games played
+func pairingDeviation(applicant models.Applicant) float64 {
+	if applicant.RatingDeviation > 0 {
+		return applicant.RatingDeviation
+	}
+	games := applicant.Wins + applicant.Losses + applicant.Draws
+	return elo.InitialDeviation / math.Sqrt(float64(1+games))
+}
+
+// ratingSystemFor returns the rating system a project is configured with, falling back
+// to Elo for applicants whose project can't be found
+func ratingSystemFor(ctx context.Context, projectID primitive.ObjectID) elo.System {
+	var project models.Project
+	if err := db.GetCollection("projects").FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		return elo.EloSystem{}
+	}
+	return ratingSystemOf(project)
+}
+
+func ratingSystemOf(project models.Project) elo.System {
+	system, ok := elo.GetSystem(project.RatingSystem)
+	if !ok {
+		return elo.EloSystem{}
+	}
+	return system
+}
+
+// applicantRating reads an applicant's stored rating, starting uncertainty tracking
+// from the system's initial values if the applicant has none yet. Applicants rated
+// before the unrounded rating was stored start from their Elo
+func applicantRating(applicant models.Applicant, system elo.System) elo.Rating {
+	rating := system.Initial()
+	rating.Value = float64(applicant.Elo)
+	if applicant.Rating != 0 {
+		rating.Value = applicant.Rating
+	}
+	if applicant.RatingDeviation > 0 {
+		rating.Deviation = applicant.RatingDeviation
+		rating.Volatility = applicant.Volatility
 	}
-	return false
+	return rating
 }
 
-func abs(x int) int {
-	if x < 0 {
-		return -x
+// ratingFields is the $set document that stores rating on an applicant
+func ratingFields(rating elo.Rating) bson.M {
+	fields := bson.M{"elo": rating.Elo(), "rating": rating.Value}
+	if rating.Deviation > 0 {
+		fields["rating_deviation"] = rating.Deviation
+		fields["volatility"] = rating.Volatility
 	}
-	return x
+	return fields
 }
 
-func resetMatchHistory(ctx context.Context, collection *mongo.Collection) {
-	_, _ = collection.UpdateMany(ctx, bson.M{}, bson.M{"$set": bson.M{"matches_played": []primitive.ObjectID{}}})
-	log.Println("Reset all applicants' match history")
+func matchReview(match models.Match) pairing.Review {
+	return pairing.Review{
+		A:        match.WinnerID,
+		B:        match.LoserID,
+		Reviewer: match.ReviewerID,
+		Judged:   match.Outcome != models.OutcomeSkip,
+	}
 }
 
 func (ac *ApplicantController) GetAll(w http.ResponseWriter, r *http.Request) {
@@ -124,84 +231,224 @@ func (ac *ApplicantController) Update(w http.ResponseWriter, r *http.Request) {
 	// TODO: Implement updating applicant
 }
 
-func (ac *ApplicantController) GetTwoForComparison(w http.ResponseWriter, r *http.Request) {
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+// Merge folds a duplicate applicant into the one in the URL: blank answers and missing
+// files are filled in from the duplicate, its matches are moved over and ratings are
+// replayed. Votes between the two are dropped, and so is the duplicate
+func (ac *ApplicantController) Merge(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	opts := options.Find().SetSort(bson.D{{Key: "elo", Value: -1}})
-	cursor, err := ac.collection.Find(ctx, bson.M{}, opts)
+	keepID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
 	if err != nil {
-		http.Error(w, "Failed to fetch applicants", http.StatusInternalServerError)
-		log.Println("MongoDB Find applicants error:", err)
+		http.Error(w, "Invalid Applicant ID", http.StatusBadRequest)
 		return
 	}
-	defer cursor.Close(ctx)
 
-	var applicants []models.Applicant
-	if err = cursor.All(ctx, &applicants); err != nil {
-		http.Error(w, "Error decoding applicants", http.StatusInternalServerError)
-		log.Println("Cursor decode error:", err)
+	var req struct {
+		DuplicateID string `json:"duplicateId"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request body", http.StatusBadRequest)
 		return
 	}
-
-	if len(applicants) < 2 {
-		http.Error(w, "Not enough applicants for comparison", http.StatusInternalServerError)
+	dropID, err := primitive.ObjectIDFromHex(req.DuplicateID)
+	if err != nil {
+		http.Error(w, "Invalid duplicateId", http.StatusBadRequest)
+		return
+	}
+	if dropID == keepID {
+		http.Error(w, "An applicant can't be merged into itself", http.StatusBadRequest)
 		return
 	}
 
-	var applicant1, applicant2 models.Applicant
-	minEloDiff := int(^uint(0) >> 1)
-	for i := 0; i < len(applicants) - 1; i++ {
-		for j := i + 1; j < len(applicants); j++ {
-			if contains(applicants[i].MatchesPlayed, applicants[j].ID) {
-				continue
+	var keep, drop models.Applicant
+	for _, a := range []struct {
+		id        primitive.ObjectID
+		applicant *models.Applicant
+	}{{keepID, &keep}, {dropID, &drop}} {
+		if err := ac.collection.FindOne(ctx, bson.M{"_id": a.id}).Decode(a.applicant); err != nil {
+			if err == mongo.ErrNoDocuments {
+				http.Error(w, "Applicant not found", http.StatusNotFound)
+				return
 			}
+			http.Error(w, "Failed to fetch applicant", http.StatusInternalServerError)
+			log.Println("MongoDB Find applicant error:", err)
+			return
+		}
+	}
+	if keep.ProjectID != drop.ProjectID {
+		http.Error(w, "Applicants belong to different projects", http.StatusBadRequest)
+		return
+	}
 
-			diff := abs(applicants[i].Elo - applicants[j].Elo)
-			if diff < minEloDiff {
-				minEloDiff = diff
-				applicant1 = applicants[i]
-				applicant2 = applicants[j]
-			}
+	project := lookupProject(ctx, keep.ProjectID)
+	if !projectAllows(project, models.ProjectDraft, models.ProjectAcceptingApplications, models.ProjectReviewing) {
+		http.Error(w, "Applicants of a finalized project can't be merged", http.StatusConflict)
+		return
+	}
+
+	var unused []*models.FileInfo
+	err = db.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		keep, unused, err = mergeApplicants(sc, project, keep, drop)
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to merge applicants", http.StatusInternalServerError)
+		log.Println("Merge applicants error:", err)
+		return
+	}
+
+	pairPools.Invalidate(keep.ProjectID)
+	setComparisonStatus(ctx, keep.ProjectID, models.ComparisonsOpen)
+	if err := refreshProjectTotals(ctx, keep.ProjectID); err != nil {
+		log.Println("Refresh project totals error:", err)
+	}
+	if bucket, err := gridfs.NewBucket(db.Client.Database("akpsi-ucsb")); err == nil {
+		for _, fileInfo := range unused {
+			deleteFile(bucket, fileInfo)
 		}
+	} else {
+		log.Println("GridFS bucket error:", err)
 	}
 
-	if applicant1.ID.IsZero() || applicant2.ID.IsZero() {
-		resetMatchHistory(ctx, ac.collection)
-		http.Error(w, "All applicants have already played, match history reset", http.StatusConflict)
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(keep)
+}
+
+type comparisonResponse struct {
+	LeaseID    primitive.ObjectID `json:"lease_id"`
+	ExpiresAt  time.Time          `json:"expires_at"`
+	Applicants []models.Applicant `json:"applicants"`
+}
+
+func (ac *ApplicantController) GetTwoForComparison(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	projectIDStr := r.URL.Query().Get("project_id")
+	if projectIDStr == "" {
+		http.Error(w, "Project ID required", http.StatusBadRequest)
 		return
 	}
 
-	// Append file data to Applicant response
-	bucket, err := gridfs.NewBucket(db.Client.Database("akpsi-ucsb"))
+	projectID, err := primitive.ObjectIDFromHex(projectIDStr)
 	if err != nil {
-		http.Error(w, "Error creating GridFS bucket: "+err.Error(), http.StatusInternalServerError)
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
 		return
 	}
 
-	applicant1.Image = fetchFile(bucket, applicant1.Image)
-	applicant1.CoverLetter = fetchFile(bucket, applicant1.CoverLetter)
-	applicant1.Resume = fetchFile(bucket, applicant1.Resume)
+	project := lookupProject(ctx, projectID)
+	if !projectAllows(project, models.ProjectReviewing) {
+		http.Error(w, "Project is not open for review", http.StatusConflict)
+		return
+	}
+	// distinct reviewers can only be counted when they say who they are
+	if project.ReviewsPerPair > 1 && reviewerID(r) == "" {
+		http.Error(w, "Reviewer ID required, this project has each pair judged by several reviewers", http.StatusBadRequest)
+		return
+	}
 
-	applicant2.Image = fetchFile(bucket, applicant2.Image)
-	applicant2.CoverLetter = fetchFile(bucket, applicant2.CoverLetter)
-	applicant2.Resume = fetchFile(bucket, applicant2.Resume)
+	// only applicants of the same project are ever paired against each other
+	var applicant1ID, applicant2ID primitive.ObjectID
+	if project.PairingStrategy == pairing.StrategySwiss {
+		applicant1ID, applicant2ID, err = nextSwissPair(ctx, projectID)
+		switch err {
+		case nil:
+		case errSwissFinished:
+			setComparisonStatus(ctx, projectID, models.ComparisonsExhausted)
+			http.Error(w, "All Swiss rounds are complete", http.StatusConflict)
+			return
+		case errRoundBusy:
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "All remaining pairs are being reviewed, try again shortly", http.StatusServiceUnavailable)
+			return
+		case errNotEnoughApplicants:
+			http.Error(w, "Not enough applicants for comparison", http.StatusInternalServerError)
+			return
+		default:
+			http.Error(w, "Failed to fetch Swiss pairing", http.StatusInternalServerError)
+			log.Println("Swiss pairing error:", err)
+			return
+		}
+	} else {
+		pool, err := pairPools.Get(ctx, projectID)
+		if err != nil {
+			http.Error(w, "Failed to fetch applicants", http.StatusInternalServerError)
+			log.Println("Load pairing pool error:", err)
+			return
+		}
 
+		if pool.Size() < 2 {
+			http.Error(w, "Not enough applicants for comparison", http.StatusInternalServerError)
+			return
+		}
 
-	applicant1.MatchesPlayed = append(applicant1.MatchesPlayed, applicant2.ID)
-	applicant2.MatchesPlayed = append(applicant2.MatchesPlayed, applicant1.ID)
+		var ok bool
+		now := time.Now()
+		applicant1ID, applicant2ID, ok = pool.TakeNext(reviewerID(r), now, now.Add(leaseDuration))
+		if !ok && pool.Available() {
+			http.Error(w, "You have reviewed every remaining pair", http.StatusConflict)
+			return
+		}
+		if !ok && pool.Reserved() > 0 {
+			w.Header().Set("Retry-After", "30")
+			http.Error(w, "All remaining pairs are being reviewed, try again shortly", http.StatusServiceUnavailable)
+			return
+		}
+		if !ok {
+			setComparisonStatus(ctx, projectID, models.ComparisonsExhausted)
+			http.Error(w, "All pairs have been reviewed, reset the project's history to start another pass", http.StatusConflict)
+			return
+		}
+	}
 
-	_, _ = ac.collection.UpdateOne(ctx, bson.M{"_id": applicant1.ID}, bson.M{"$set": bson.M{"matches_played" : applicant1.MatchesPlayed}})
-	_, _ = ac.collection.UpdateOne(ctx, bson.M{"_id": applicant2.ID}, bson.M{"$set": bson.M{"matches_played" : applicant2.MatchesPlayed}})
+	lease, err := createLease(ctx, projectID, applicant1ID, applicant2ID, reviewerID(r))
+	if err != nil {
+		pairPools.Invalidate(projectID)
+		http.Error(w, "Failed to lease applicants", http.StatusInternalServerError)
+		log.Println("MongoDB Insert lease error:", err)
+		return
+	}
+
+	var applicant1, applicant2 models.Applicant
+	if err := ac.collection.FindOne(ctx, bson.M{"_id": applicant1ID}).Decode(&applicant1); err != nil {
+		pairPools.Invalidate(projectID)
+		http.Error(w, "Failed to fetch applicants", http.StatusInternalServerError)
+		log.Println("MongoDB Find applicant error:", err)
+		return
+	}
+	if err := ac.collection.FindOne(ctx, bson.M{"_id": applicant2ID}).Decode(&applicant2); err != nil {
+		pairPools.Invalidate(projectID)
+		http.Error(w, "Failed to fetch applicants", http.StatusInternalServerError)
+		log.Println("MongoDB Find applicant error:", err)
+		return
+	}
+
+	// files are fetched separately, see FileController.Serve. Their links last as long
+	// as the reviewer holds the pair
+	setFileURLs(&applicant1, lease.ID)
+	setFileURLs(&applicant2, lease.ID)
 
 	w.Header().Set("Content-Type", "application/json")
-	json.NewEncoder(w).Encode([]models.Applicant{applicant1, applicant2})
+	json.NewEncoder(w).Encode(comparisonResponse{
+		LeaseID:    lease.ID,
+		ExpiresAt:  lease.ExpiresAt,
+		Applicants: []models.Applicant{applicant1, applicant2},
+	})
 }
 
 func (ac *ApplicantController) UpdateElo(w http.ResponseWriter, r *http.Request) {
 	var request struct {
 		WinnerID string `json:"winnerId"`
 		LoserID  string `json:"loserId"`
+		// optional, "draw" or "skip" when the reviewer couldn't pick a winner
+		Outcome string `json:"outcome"`
+		// optional strength of preference for the winner, elo.MinMargin to elo.MaxMargin
+		Margin int `json:"margin"`
+		// the lease the pair was served under, also used as the idempotency key when
+		// no Idempotency-Key header is sent
+		LeaseID string `json:"leaseId"`
 	}
 
 	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
@@ -210,6 +457,24 @@ func (ac *ApplicantController) UpdateElo(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	outcome := request.Outcome
+	if outcome == "" {
+		outcome = models.OutcomeWin
+	}
+	if outcome != models.OutcomeWin && outcome != models.OutcomeDraw && outcome != models.OutcomeSkip {
+		http.Error(w, "Invalid outcome", http.StatusBadRequest)
+		return
+	}
+
+	if request.Margin != 0 && (request.Margin < elo.MinMargin || request.Margin > elo.MaxMargin) {
+		http.Error(w, fmt.Sprintf("Margin must be between %d and %d", elo.MinMargin, elo.MaxMargin), http.StatusBadRequest)
+		return
+	}
+	// a margin only means something when there is a winner
+	if outcome != models.OutcomeWin {
+		request.Margin = 0
+	}
+
 	// Convert string IDs to ObjectIDs
 	winnerID, err := primitive.ObjectIDFromHex(request.WinnerID)
 	if err != nil {
@@ -222,6 +487,19 @@ func (ac *ApplicantController) UpdateElo(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Invalid Loser ID format", http.StatusBadRequest)
 		return
 	}
+	if winnerID == loserID {
+		http.Error(w, "An applicant can't be compared with itself", http.StatusBadRequest)
+		return
+	}
+
+	idempotencyKey := r.Header.Get("Idempotency-Key")
+	if idempotencyKey == "" && request.LeaseID != "" {
+		idempotencyKey = "lease:" + request.LeaseID
+	}
+	if len(idempotencyKey) > maxIdempotencyKeyLength {
+		http.Error(w, fmt.Sprintf("Idempotency key can't be longer than %d characters", maxIdempotencyKeyLength), http.StatusBadRequest)
+		return
+	}
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
@@ -235,47 +513,385 @@ func (ac *ApplicantController) UpdateElo(w http.ResponseWriter, r *http.Request)
 		http.Error(w, "Loser not found", http.StatusNotFound)
 		return
 	}
+	if winner.ProjectID != loser.ProjectID {
+		http.Error(w, "Applicants belong to different projects", http.StatusBadRequest)
+		return
+	}
 
-	winnerElo, loserElo := elo.CalculateElo(winner.Elo, loser.Elo, true)
-
-	winner.Elo = winnerElo
-	loser.Elo = loserElo
+	// a retry of a vote that already went through gets the original result, before
+	// the lease it consumed is checked. Keys are only looked up among the reviewer's own
+	// votes in the project, so one can't fetch another's
+	if idempotencyKey != "" && ac.writeRecordedVote(ctx, w, winner.ProjectID, reviewerID(r), idempotencyKey, winnerID, loserID) {
+		return
+	}
 
-	winner.Wins += 1
-	loser.Losses += 1
+	// only pairs served by getTwoForComparison can be voted on, so two reviewers can't
+	// judge a pair one of them holds
+	if request.LeaseID == "" {
+		http.Error(w, "Lease ID required, vote on a pair from getTwoForComparison", http.StatusBadRequest)
+		return
+	}
+	lease, err := checkLease(ctx, request.LeaseID, reviewerID(r), winnerID, loserID)
+	switch err {
+	case nil:
+	case errLeaseNotFound:
+		http.Error(w, "Lease not found", http.StatusNotFound)
+		return
+	case errLeaseExpired:
+		http.Error(w, "Lease expired, the pair may have been shown to another reviewer", http.StatusConflict)
+		return
+	case errLeaseMismatch:
+		http.Error(w, "Lease does not cover these applicants", http.StatusBadRequest)
+		return
+	case errLeaseReviewer:
+		http.Error(w, "Lease belongs to another reviewer", http.StatusForbidden)
+		return
+	default:
+		http.Error(w, "Failed to fetch lease", http.StatusInternalServerError)
+		log.Println("MongoDB Find lease error:", err)
+		return
+	}
 
-	updateWinner := bson.M{"$set": bson.M{"elo": winner.Elo}, "$inc": bson.M{"wins": 1}}
-	updateLoser := bson.M{"$set": bson.M{"elo": loser.Elo}, "$inc": bson.M{"losses": 1}}
-	
-	if _, err := ac.collection.UpdateOne(ctx, bson.M{"_id": winnerID}, updateWinner); err != nil {
-		http.Error(w, "Failed to update winner", http.StatusInternalServerError)
+	project := lookupProject(ctx, winner.ProjectID)
+	if !projectAllows(project, models.ProjectReviewing) {
+		http.Error(w, "Project is not open for review", http.StatusConflict)
 		return
 	}
-	if _, err := ac.collection.UpdateOne(ctx, bson.M{"_id": loserID}, updateLoser); err != nil {
-		http.Error(w, "Failed to update loser", http.StatusInternalServerError)
+	// distinct reviewers can only be counted when they say who they are
+	if project.ReviewsPerPair > 1 && reviewerID(r) == "" {
+		http.Error(w, "Reviewer ID required, this project has each pair judged by several reviewers", http.StatusBadRequest)
 		return
-	}	
+	}
 
-    w.WriteHeader(http.StatusOK)
+	match := models.Match{
+		ID:             primitive.NewObjectID(),
+		ProjectID:      winner.ProjectID,
+		WinnerID:       winnerID,
+		LoserID:        loserID,
+		Outcome:        outcome,
+		Margin:         request.Margin,
+		ReviewerID:     reviewerID(r),
+		Pass:           project.Pass,
+		IdempotencyKey: idempotencyKey,
+		CreatedAt:      time.Now(),
+	}
+
+	// concurrent votes on the same applicant conflict inside the transaction and are
+	// retried against the ratings the other vote committed
+	err = db.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		match, winner, loser, err = applyVote(sc, project, match, lease.ID)
+		return err
+	})
+	if err != nil {
+		// a concurrent retry of the same vote committed first
+		if mongo.IsDuplicateKeyError(err) && idempotencyKey != "" && ac.writeRecordedVote(ctx, w, match.ProjectID, match.ReviewerID, idempotencyKey, winnerID, loserID) {
+			return
+		}
+		if errors.Is(err, errLeaseUsed) {
+			http.Error(w, "Lease was already used for another vote", http.StatusConflict)
+			return
+		}
+		http.Error(w, "Failed to record vote", http.StatusInternalServerError)
+		log.Println("Apply vote error:", err)
+		return
+	}
+
+	if pool, ok := pairPools.Peek(match.ProjectID); ok {
+		if outcome != models.OutcomeSkip {
+			pool.SetRating(winnerID, winner.Elo, pairingDeviation(winner))
+			pool.SetRating(loserID, loser.Elo, pairingDeviation(loser))
+		}
+		pool.Record(matchReview(match))
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(match)
 }
 
-// Helper function for file fetching
-func fetchFile(bucket *gridfs.Bucket, fileInfo *models.FileInfo) *models.FileInfo {
-	if fileInfo != nil {
-		fileID, err := primitive.ObjectIDFromHex(fileInfo.FileID)
-		if err != nil {
-			return nil
+// writeRecordedVote answers a vote whose idempotency key the reviewer has used before in
+// the project with the match it recorded. Returns false if the key hasn't been used yet
+func (ac *ApplicantController) writeRecordedVote(ctx context.Context, w http.ResponseWriter, projectID primitive.ObjectID, reviewer, key string, winnerID, loserID primitive.ObjectID) bool {
+	var match models.Match
+	err := ac.matches.FindOne(ctx, bson.M{"project_id": projectID, "reviewer_id": reviewer, "idempotency_key": key}).Decode(&match)
+	if err == mongo.ErrNoDocuments {
+		return false
+	}
+	if err != nil {
+		http.Error(w, "Failed to fetch match", http.StatusInternalServerError)
+		log.Println("MongoDB Find match error:", err)
+		return true
+	}
+
+	if !samePair(match.WinnerID, match.LoserID, winnerID, loserID) {
+		http.Error(w, "Idempotency key was already used for a different vote", http.StatusConflict)
+		return true
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(match)
+	return true
+}
+
+// applyVote reads both applicants and writes everything a vote changes: their ratings,
+// counters and match history, the match record, the lease it was cast under, the Swiss
+// pairing and the project's progress. Run it in a transaction so all of it commits or
+// none does. Returns the completed match and both applicants as updated
+func applyVote(sc mongo.SessionContext, project models.Project, match models.Match, leaseID primitive.ObjectID) (models.Match, models.Applicant, models.Applicant, error) {
+	applicants := db.GetCollection("applicants")
+
+	var winner, loser models.Applicant
+	if err := applicants.FindOne(sc, bson.M{"_id": match.WinnerID}).Decode(&winner); err != nil {
+		return match, winner, loser, err
+	}
+	if err := applicants.FindOne(sc, bson.M{"_id": match.LoserID}).Decode(&loser); err != nil {
+		return match, winner, loser, err
+	}
+
+	match.WinnerEloBefore, match.WinnerEloAfter = winner.Elo, winner.Elo
+	match.LoserEloBefore, match.LoserEloAfter = loser.Elo, loser.Elo
+
+	updateWinner := bson.M{}
+	updateLoser := bson.M{}
+
+	// skips are recorded but leave ratings, counters and who has met whom alone
+	if match.Outcome != models.OutcomeSkip {
+		updateWinner["$addToSet"] = bson.M{"matches_played": loser.ID}
+		updateLoser["$addToSet"] = bson.M{"matches_played": winner.ID}
+
+		system := ratingSystemOf(project)
+		newWinner, newLoser := system.Update(applicantRating(winner, system), applicantRating(loser, system), outcomeScore(match.Outcome), match.Margin)
+		match.WinnerEloAfter, match.LoserEloAfter = newWinner.Elo(), newLoser.Elo()
+
+		winnerCounter, loserCounter := outcomeCounters(match.Outcome)
+		updateWinner["$set"], updateWinner["$inc"] = ratingFields(newWinner), bson.M{winnerCounter: 1}
+		updateLoser["$set"], updateLoser["$inc"] = ratingFields(newLoser), bson.M{loserCounter: 1}
+
+		winner.Elo, winner.Rating, winner.RatingDeviation = newWinner.Elo(), newWinner.Value, newWinner.Deviation
+		loser.Elo, loser.Rating, loser.RatingDeviation = newLoser.Elo(), newLoser.Value, newLoser.Deviation
+		if match.Outcome == models.OutcomeDraw {
+			winner.Draws++
+			loser.Draws++
+		} else {
+			winner.Wins++
+			loser.Losses++
 		}
+	}
 
-		var buf bytes.Buffer
-		_, err = bucket.DownloadToStream(fileID, &buf)
-		if err != nil {
-			return nil
+	if len(updateWinner) > 0 {
+		if _, err := applicants.UpdateOne(sc, bson.M{"_id": winner.ID}, updateWinner); err != nil {
+			return match, winner, loser, err
 		}
+		if _, err := applicants.UpdateOne(sc, bson.M{"_id": loser.ID}, updateLoser); err != nil {
+			return match, winner, loser, err
+		}
+	}
 
-		fileInfo.Data = base64.StdEncoding.EncodeToString(buf.Bytes())
+	if _, err := db.GetCollection("matches").InsertOne(sc, match); err != nil {
+		return match, winner, loser, err
+	}
+
+	// checkLease ran before the transaction, another vote may have consumed the lease since
+	result, err := db.GetCollection("leases").DeleteOne(sc, bson.M{"_id": leaseID})
+	if err != nil {
+		return match, winner, loser, err
+	}
+	if result.DeletedCount != 1 {
+		return match, winner, loser, errLeaseUsed
+	}
+
+	if match.Outcome != models.OutcomeSkip {
+		if _, err := db.GetCollection("projects").UpdateOne(sc, bson.M{"_id": match.ProjectID}, bson.M{"$inc": bson.M{"completedComparisons": 1}}); err != nil {
+			return match, winner, loser, err
+		}
+	}
+
+	// a skipped Swiss pairing still needs a result, it goes back in line
+	if match.Outcome == models.OutcomeSkip {
+		if err := releaseSwissPairing(sc, match.ProjectID, match.WinnerID, match.LoserID); err != nil {
+			return match, winner, loser, err
+		}
+	} else if err := completeSwissPairing(sc, match.ProjectID, match.WinnerID, match.LoserID, match.ID); err != nil {
+		return match, winner, loser, err
+	}
+	return match, winner, loser, nil
+}
+
+// mergeApplicants does the work of Merge inside the caller's transaction. Returns the
+// merged applicant and the duplicate's files that weren't kept, to be deleted once the
+// transaction commits
+func mergeApplicants(sc mongo.SessionContext, project models.Project, keep, drop models.Applicant) (models.Applicant, []*models.FileInfo, error) {
+	applicants := db.GetCollection("applicants")
+	matches := db.GetCollection("matches")
+	pair := []primitive.ObjectID{keep.ID, drop.ID}
+
+	// a vote between the two was a vote between the same person
+	between := bson.M{"winner_id": bson.M{"$in": pair}, "loser_id": bson.M{"$in": pair}}
+	counted := bson.M{"outcome": bson.M{"$ne": models.OutcomeSkip}}
+	if project.HistoryResetAt != nil {
+		counted["created_at"] = bson.M{"$gt": *project.HistoryResetAt}
+	}
+	progress, err := matches.CountDocuments(sc, bson.M{"$and": []bson.M{between, counted}})
+	if err != nil {
+		return keep, nil, err
+	}
+	if _, err := matches.DeleteMany(sc, between); err != nil {
+		return keep, nil, err
+	}
+	if progress > 0 {
+		if _, err := db.GetCollection("projects").UpdateOne(sc,
+			bson.M{"_id": project.ID},
+			bson.M{"$inc": bson.M{"completedComparisons": -progress}},
+		); err != nil {
+			return keep, nil, err
+		}
+	}
+
+	if _, err := matches.UpdateMany(sc, bson.M{"winner_id": drop.ID}, bson.M{"$set": bson.M{"winner_id": keep.ID}}); err != nil {
+		return keep, nil, err
+	}
+	if _, err := matches.UpdateMany(sc, bson.M{"loser_id": drop.ID}, bson.M{"$set": bson.M{"loser_id": keep.ID}}); err != nil {
+		return keep, nil, err
+	}
+
+	// everyone who played the duplicate has now played the kept applicant
+	if _, err := applicants.UpdateMany(sc,
+		bson.M{"project_id": project.ID, "matches_played": drop.ID},
+		bson.M{"$addToSet": bson.M{"matches_played": keep.ID}},
+	); err != nil {
+		return keep, nil, err
+	}
+	if _, err := applicants.UpdateMany(sc,
+		bson.M{"project_id": project.ID},
+		bson.M{"$pull": bson.M{"matches_played": drop.ID}},
+	); err != nil {
+		return keep, nil, err
+	}
+	// drop's other duplicates are now keep's, and keep is no longer a duplicate of the
+	// applicant it absorbed, rather than one of itself
+	if _, err := applicants.UpdateMany(sc,
+		bson.M{"project_id": project.ID, "duplicate_of": drop.ID, "_id": bson.M{"$ne": keep.ID}},
+		bson.M{"$set": bson.M{"duplicate_of": keep.ID}},
+	); err != nil {
+		return keep, nil, err
+	}
+	if _, err := applicants.UpdateOne(sc,
+		bson.M{"_id": keep.ID, "duplicate_of": drop.ID},
+		bson.M{"$unset": bson.M{"duplicate_of": ""}},
+	); err != nil {
+		return keep, nil, err
+	}
+
+	if err := applicants.FindOne(sc, bson.M{"_id": keep.ID}).Decode(&keep); err != nil {
+		return keep, nil, err
+	}
+	var unused []*models.FileInfo
+	keep, unused = combineApplicants(keep, drop)
+	if _, err := applicants.ReplaceOne(sc, bson.M{"_id": keep.ID}, keep); err != nil {
+		return keep, nil, err
+	}
+	if _, err := applicants.DeleteOne(sc, bson.M{"_id": drop.ID}); err != nil {
+		return keep, nil, err
+	}
+	if _, err := db.GetCollection("leases").DeleteMany(sc, bson.M{"$or": []bson.M{
+		{"applicant_a": drop.ID},
+		{"applicant_b": drop.ID},
+	}}); err != nil {
+		return keep, nil, err
+	}
+
+	// the open Swiss round still pairs the duplicate, the next one is drawn without it
+	if _, err := db.GetCollection("rounds").UpdateMany(sc,
+		bson.M{"project_id": project.ID, "completed_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"completed_at": time.Now()}},
+	); err != nil {
+		return keep, nil, err
+	}
+
+	if _, _, err := replayProject(sc, project.ID); err != nil {
+		return keep, nil, err
+	}
+	err = applicants.FindOne(sc, bson.M{"_id": keep.ID}).Decode(&keep)
+	return keep, unused, err
+}
+
+// combineApplicants fills keep's blank answers and missing files from drop. Returns the
+// combined applicant and drop's files that weren't used
+func combineApplicants(keep, drop models.Applicant) (models.Applicant, []*models.FileInfo) {
+	for _, field := range []struct {
+		keep *string
+		drop string
+	}{
+		{&keep.FirstName, drop.FirstName},
+		{&keep.LastName, drop.LastName},
+		{&keep.Major, drop.Major},
+		{&keep.Year, drop.Year},
+		{&keep.Email, drop.Email},
+	} {
+		if *field.keep == "" {
+			*field.keep = field.drop
+		}
+	}
+
+	var unused []*models.FileInfo
+	for key, value := range drop.Answers {
+		if _, ok := keep.Answers[key]; !ok {
+			if keep.Answers == nil {
+				keep.Answers = make(map[string]interface{})
+			}
+			keep.Answers[key] = value
+		} else if fileInfo, ok := answerFile(value); ok {
+			unused = append(unused, fileInfo)
+		}
+	}
+
+	for _, file := range []struct {
+		keep **models.FileInfo
+		drop *models.FileInfo
+	}{
+		{&keep.Resume, drop.Resume},
+		{&keep.CoverLetter, drop.CoverLetter},
+		{&keep.Image, drop.Image},
+	} {
+		if file.drop == nil {
+			continue
+		}
+		if *file.keep == nil {
+			*file.keep = file.drop
+		} else {
+			unused = append(unused, file.drop)
+		}
+	}
+
+	played := make([]primitive.ObjectID, 0, len(keep.MatchesPlayed)+len(drop.MatchesPlayed))
+	seen := map[primitive.ObjectID]bool{keep.ID: true, drop.ID: true}
+	for _, id := range append(keep.MatchesPlayed, drop.MatchesPlayed...) {
+		if !seen[id] {
+			seen[id] = true
+			played = append(played, id)
+		}
+	}
+	keep.MatchesPlayed = played
+
+	if keep.DuplicateOf != nil && *keep.DuplicateOf == drop.ID {
+		keep.DuplicateOf = nil
+	}
+	return keep, unused
+}
+
+// setFileURLs gives each of the applicant's files a link that works while the lease is held
+func setFileURLs(applicant *models.Applicant, leaseID primitive.ObjectID) {
+	for _, fileInfo := range []*models.FileInfo{applicant.Image, applicant.CoverLetter, applicant.Resume} {
+		if fileInfo != nil {
+			fileInfo.URL = fileURL(fileInfo.FileID, leaseID)
+		}
+	}
+	for key, value := range applicant.Answers {
+		if fileInfo, ok := answerFile(value); ok {
+			fileInfo.URL = fileURL(fileInfo.FileID, leaseID)
+			applicant.Answers[key] = fileInfo
+		}
 	}
-	return fileInfo
 }
 
 func (ac *ApplicantController) GetRankings(w http.ResponseWriter, r *http.Request) {
@@ -294,6 +910,12 @@ func (ac *ApplicantController) GetRankings(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	method := r.URL.Query().Get("method")
+	if method != "" && method != "elo" && method != "bt" {
+		http.Error(w, "Unknown ranking method", http.StatusBadRequest)
+		return
+	}
+
 	log.Println("Project ID: ", projectID)
 	// Find all applicants for this project, sorted by Elo
 	opts := options.Find().SetSort(bson.D{{Key: "elo", Value: -1}})
@@ -310,7 +932,86 @@ func (ac *ApplicantController) GetRankings(w http.ResponseWriter, r *http.Reques
 		return
 	}
 
+	if method == "bt" {
+		btRankings, err := ac.bradleyTerryRankings(ctx, projectID, rankings)
+		if err != nil {
+			http.Error(w, "Failed to fit Bradley-Terry rankings", http.StatusInternalServerError)
+			log.Println("Bradley-Terry rankings error:", err)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(btRankings)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(rankings)
 }
 
+type bradleyTerryRanking struct {
+	models.Applicant
+	Strength float64 `json:"bt_strength"`
+	Rating   float64 `json:"bt_rating"`
+	StdError float64 `json:"bt_std_error"`
+}
+
+// bradleyTerryRankings fits every recorded match of the project at once and orders the
+// applicants by fitted strength, so the result doesn't depend on the order of the votes
+func (ac *ApplicantController) bradleyTerryRankings(ctx context.Context, projectID primitive.ObjectID, applicants []models.Applicant) ([]bradleyTerryRanking, error) {
+	cursor, err := ac.matches.Find(ctx, bson.M{"project_id": projectID})
+	if err != nil {
+		return nil, err
+	}
+	var matches []models.Match
+	if err = cursor.All(ctx, &matches); err != nil {
+		return nil, err
+	}
+
+	index := make(map[primitive.ObjectID]int, len(applicants))
+	for i, applicant := range applicants {
+		index[applicant.ID] = i
+	}
+
+	comparisons := make([]elo.Comparison, 0, len(matches))
+	for _, match := range matches {
+		if match.Outcome == models.OutcomeSkip {
+			continue
+		}
+		winner, ok := index[match.WinnerID]
+		if !ok {
+			continue
+		}
+		loser, ok := index[match.LoserID]
+		if !ok {
+			continue
+		}
+		comparisons = append(comparisons, elo.Comparison{
+			Winner: winner,
+			Loser:  loser,
+			Draw:   match.Outcome == models.OutcomeDraw,
+			Margin: match.Margin,
+		})
+	}
+
+	strengths, err := elo.BradleyTerry(len(applicants), comparisons)
+	if err != nil {
+		return nil, err
+	}
+
+	rankings := make([]bradleyTerryRanking, len(applicants))
+	for i, applicant := range applicants {
+		rankings[i] = bradleyTerryRanking{
+			Applicant: applicant,
+			Strength:  strengths[i].Strength,
+			Rating:    strengths[i].Rating,
+			StdError:  strengths[i].StdError,
+		}
+	}
+	sort.SliceStable(rankings, func(i, j int) bool {
+		return rankings[i].Strength > rankings[j].Strength
+	})
+
+	return rankings, nil
+}
+
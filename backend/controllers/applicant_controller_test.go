@@ -0,0 +1,95 @@
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func TestCombineApplicants(t *testing.T) {
+	originalID, duplicateID, otherID := primitive.NewObjectID(), primitive.NewObjectID(), primitive.NewObjectID()
+	a, b := primitive.NewObjectID(), primitive.NewObjectID()
+	resume := &models.FileInfo{FileID: "r", UniqueName: "1_resume.pdf"}
+	image := &models.FileInfo{FileID: "i", UniqueName: "1_image.png"}
+	newImage := &models.FileInfo{FileID: "i2", UniqueName: "2_image.png"}
+
+	original := func() models.Applicant {
+		return models.Applicant{
+			ID:            originalID,
+			FirstName:     "Ada",
+			Email:         "ada@x.edu",
+			Resume:        resume,
+			Image:         image,
+			Answers:       map[string]interface{}{"why": "Because"},
+			MatchesPlayed: []primitive.ObjectID{duplicateID, a},
+		}
+	}
+	duplicate := func(of primitive.ObjectID) models.Applicant {
+		return models.Applicant{
+			ID:            duplicateID,
+			FirstName:     "Ada",
+			Image:         newImage,
+			Answers:       map[string]interface{}{"clubs": "Chess"},
+			MatchesPlayed: []primitive.ObjectID{originalID, b, a},
+			DuplicateOf:   &of,
+		}
+	}
+
+	tests := []struct {
+		name            string
+		keep, drop      models.Applicant
+		wantDuplicateOf *primitive.ObjectID
+		wantPlayed      []primitive.ObjectID
+		wantUnused      []*models.FileInfo
+	}{
+		{
+			name:       "duplicate into original",
+			keep:       original(),
+			drop:       duplicate(originalID),
+			wantPlayed: []primitive.ObjectID{a, b},
+			wantUnused: []*models.FileInfo{newImage},
+		},
+		{
+			name:       "original into its own duplicate",
+			keep:       duplicate(originalID),
+			drop:       original(),
+			wantPlayed: []primitive.ObjectID{b, a},
+			wantUnused: []*models.FileInfo{image},
+		},
+		{
+			name:            "original into a duplicate of another applicant",
+			keep:            duplicate(otherID),
+			drop:            original(),
+			wantDuplicateOf: &otherID,
+			wantPlayed:      []primitive.ObjectID{b, a},
+			wantUnused:      []*models.FileInfo{image},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, unused := combineApplicants(tt.keep, tt.drop)
+			if got.ID != tt.keep.ID {
+				t.Fatalf("combined applicant has ID %s, want the kept %s", got.ID.Hex(), tt.keep.ID.Hex())
+			}
+			if !reflect.DeepEqual(got.DuplicateOf, tt.wantDuplicateOf) {
+				t.Errorf("DuplicateOf = %v, want %v", got.DuplicateOf, tt.wantDuplicateOf)
+			}
+			if !reflect.DeepEqual(got.MatchesPlayed, tt.wantPlayed) {
+				t.Errorf("MatchesPlayed = %v, want %v", got.MatchesPlayed, tt.wantPlayed)
+			}
+			if !reflect.DeepEqual(unused, tt.wantUnused) {
+				t.Errorf("unused files = %v, want %v", unused, tt.wantUnused)
+			}
+			if got.Email != "ada@x.edu" || got.Resume != resume {
+				t.Errorf("blank email and resume weren't filled from the other applicant: %q, %v", got.Email, got.Resume)
+			}
+			if got.Answers["why"] != "Because" || got.Answers["clubs"] != "Chess" {
+				t.Errorf("answers = %v, want both applicants' answers", got.Answers)
+			}
+		})
+	}
+}
@@ -0,0 +1,268 @@
+package controllers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"time"
+
+	"backend/db"
+	"backend/models"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+)
+
+type FileController struct {
+	bucket *gridfs.Bucket
+}
+
+func NewFileController() *FileController {
+	bucket, err := gridfs.NewBucket(db.Client.Database("akpsi-ucsb"))
+	if err != nil {
+		log.Fatal("Failed to create GridFS bucket:", err)
+	}
+	return &FileController{
+		bucket: bucket,
+	}
+}
+
+// Serve streams an uploaded file out of GridFS. Range requests are supported so PDFs can
+// be viewed page by page, and files never change so their ID doubles as the ETag.
+// Files hold applicants' personal details, so every request needs the ?token= issued
+// with the file's URL, and works only while the lease it was issued under is held.
+// ?download=1 asks the browser to save the file instead of showing it
+func (fc *FileController) Serve(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	fileID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid File ID", http.StatusBadRequest)
+		return
+	}
+
+	leaseID, err := checkFileToken(r.URL.Query().Get("token"), fileID.Hex())
+	if err != nil {
+		http.Error(w, "Invalid file link: "+err.Error(), http.StatusForbidden)
+		return
+	}
+	lease, err := fileLease(ctx, leaseID, reviewerID(r), time.Now())
+	if err != nil {
+		if errors.Is(err, errFileTokenExpired) || errors.Is(err, errFileTokenReviewer) {
+			http.Error(w, "Invalid file link: "+err.Error(), http.StatusForbidden)
+			return
+		}
+		http.Error(w, "Failed to check file link", http.StatusInternalServerError)
+		log.Println("MongoDB Find lease error:", err)
+		return
+	}
+
+	file, err := openGridFSFile(fc.bucket, fileID)
+	if err != nil {
+		if errors.Is(err, gridfs.ErrFileNotFound) {
+			http.Error(w, "File not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to open file", http.StatusInternalServerError)
+		log.Println("GridFS open error:", err)
+		return
+	}
+	defer file.Close()
+
+	info := file.stream.GetFile()
+	if contentType := gridFSContentType(info); contentType != "" {
+		w.Header().Set("Content-Type", contentType)
+	}
+	disposition := "inline"
+	if r.URL.Query().Get("download") != "" {
+		disposition = "attachment"
+	}
+	w.Header().Set("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": info.Name}))
+	w.Header().Set("ETag", `"`+fileID.Hex()+`"`)
+	// the browser may keep the file only as long as the link is good
+	w.Header().Set("Cache-Control", fmt.Sprintf("private, max-age=%d", int(time.Until(lease.ExpiresAt).Seconds())))
+
+	// ServeContent handles Range, If-None-Match and Content-Length
+	http.ServeContent(w, r, info.Name, info.UploadDate, file)
+}
+
+// file token helper functions
+
+var (
+	errFileTokenMissing  = errors.New("missing token")
+	errFileTokenInvalid  = errors.New("token is not valid for this file")
+	errFileTokenExpired  = errors.New("link is no longer valid")
+	errFileTokenReviewer = errors.New("link was issued to another reviewer")
+)
+
+var (
+	fileTokenSecretOnce sync.Once
+	fileTokenSecretKey  []byte
+)
+
+// fileTokenSecret signs file links. Without FILE_TOKEN_SECRET a random one is used, so
+// links stop working when the server restarts
+func fileTokenSecret() []byte {
+	fileTokenSecretOnce.Do(func() {
+		if secret := os.Getenv("FILE_TOKEN_SECRET"); secret != "" {
+			fileTokenSecretKey = []byte(secret)
+			return
+		}
+		log.Println("Warning: FILE_TOKEN_SECRET not set, file links won't survive a restart")
+		fileTokenSecretKey = make([]byte, 32)
+		if _, err := rand.Read(fileTokenSecretKey); err != nil {
+			log.Fatal("Failed to generate file token secret:", err)
+		}
+	})
+	return fileTokenSecretKey
+}
+
+// fileToken grants access to a file for as long as the lease it was served under is held.
+// It is "<lease ID, hex>.<hex HMAC-SHA256 of it and the file ID>"
+func fileToken(fileID string, leaseID primitive.ObjectID) string {
+	return leaseID.Hex() + "." + hex.EncodeToString(signFileToken(fileID, leaseID.Hex()))
+}
+
+func signFileToken(fileID, leaseID string) []byte {
+	mac := hmac.New(sha256.New, fileTokenSecret())
+	mac.Write([]byte(fileID + "." + leaseID))
+	return mac.Sum(nil)
+}
+
+// checkFileToken makes sure a token was issued for the file and returns the lease it was
+// issued under
+func checkFileToken(token, fileID string) (primitive.ObjectID, error) {
+	if token == "" {
+		return primitive.NilObjectID, errFileTokenMissing
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return primitive.NilObjectID, errFileTokenInvalid
+	}
+	leaseIDStr, signature := parts[0], parts[1]
+
+	mac, err := hex.DecodeString(signature)
+	if err != nil || !hmac.Equal(mac, signFileToken(fileID, leaseIDStr)) {
+		return primitive.NilObjectID, errFileTokenInvalid
+	}
+	leaseID, err := primitive.ObjectIDFromHex(leaseIDStr)
+	if err != nil {
+		return primitive.NilObjectID, errFileTokenInvalid
+	}
+	return leaseID, nil
+}
+
+// fileLease loads the lease a file link was issued under. Voting or releasing deletes the
+// lease, which ends the link along with it. Requests that identify their reviewer must be
+// from the lease's; the ones a browser makes for an <img> or a new tab can't, and rely on
+// the lease alone
+func fileLease(ctx context.Context, leaseID primitive.ObjectID, reviewer string, now time.Time) (models.Lease, error) {
+	var lease models.Lease
+	if err := db.GetCollection("leases").FindOne(ctx, bson.M{"_id": leaseID}).Decode(&lease); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return lease, errFileTokenExpired
+		}
+		return lease, err
+	}
+	if !lease.ExpiresAt.After(now) {
+		return lease, errFileTokenExpired
+	}
+	if reviewer != "" && lease.ReviewerID != "" && lease.ReviewerID != reviewer {
+		return lease, errFileTokenReviewer
+	}
+	return lease, nil
+}
+
+// fileURL is a link to the file that works while the lease is held
+func fileURL(fileID string, leaseID primitive.ObjectID) string {
+	return "/api/files/" + fileID + "?token=" + url.QueryEscape(fileToken(fileID, leaseID))
+}
+
+// gridFSContentType is the type the file was uploaded with, or one guessed from its name.
+// Empty lets ServeContent sniff it
+func gridFSContentType(info *gridfs.File) string {
+	var metadata struct {
+		ContentType string `bson:"contentType"`
+	}
+	if info.Metadata != nil && bson.Unmarshal(info.Metadata, &metadata) == nil && metadata.ContentType != "" {
+		return metadata.ContentType
+	}
+	return mime.TypeByExtension(path.Ext(info.Name))
+}
+
+// gridFSFile makes a GridFS download seekable for http.ServeContent. Seeking forward
+// skips ahead in the current stream, seeking back reopens it
+type gridFSFile struct {
+	bucket *gridfs.Bucket
+	id     primitive.ObjectID
+	stream *gridfs.DownloadStream
+	size   int64
+	// position of the stream, and where the next read should start
+	pos, offset int64
+}
+
+func openGridFSFile(bucket *gridfs.Bucket, id primitive.ObjectID) (*gridFSFile, error) {
+	stream, err := bucket.OpenDownloadStream(id)
+	if err != nil {
+		return nil, err
+	}
+	return &gridFSFile{bucket: bucket, id: id, stream: stream, size: stream.GetFile().Length}, nil
+}
+
+func (f *gridFSFile) Read(p []byte) (int, error) {
+	if f.offset < f.pos {
+		f.stream.Close()
+		stream, err := f.bucket.OpenDownloadStream(f.id)
+		if err != nil {
+			return 0, err
+		}
+		f.stream, f.pos = stream, 0
+	}
+	if f.offset > f.pos {
+		skipped, err := f.stream.Skip(f.offset - f.pos)
+		f.pos += skipped
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := f.stream.Read(p)
+	f.pos += int64(n)
+	f.offset = f.pos
+	return n, err
+}
+
+func (f *gridFSFile) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("seek before start of file")
+	}
+	f.offset = offset
+	return offset, nil
+}
+
+func (f *gridFSFile) Close() error {
+	return f.stream.Close()
+}
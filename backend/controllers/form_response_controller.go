@@ -2,28 +2,53 @@ package controllers
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"log"
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	"backend/db"
+	"backend/elo"
 	"backend/models"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type FormResponseController struct {
 	collection *mongo.Collection
+	// the body limit, project lookup and storage of a checked submission, replaced in tests
+	maxBodyBytes int64
+	findProject  func(ctx context.Context, formID string) (models.Project, error)
+	store        func(ctx context.Context, w http.ResponseWriter, applicant models.Applicant, files []formAnswer, delivery webhookDelivery)
 }
 
 func NewFormResponseController() *FormResponseController {
-	return &FormResponseController{
-		collection: db.GetCollection("applicants"),
+	fc := &FormResponseController{
+		collection:   db.GetCollection("applicants"),
+		maxBodyBytes: maxFormBodyBytes,
+		findProject:  projectForForm,
 	}
+	fc.store = fc.storeApplicant
+	return fc
+}
+
+// formSubmission is the body the form script posts
+type formSubmission struct {
+	FormId string `json:"formId"`
+	models.FormResponses
 }
 
 func (fc *FormResponseController) HandleFormResponse(w http.ResponseWriter, r *http.Request) {
@@ -32,29 +57,94 @@ func (fc *FormResponseController) HandleFormResponse(w http.ResponseWriter, r *h
 		return
 	}
 
-	var requestData struct {
-		FormId string              `json:"formId"`
-		models.FormResponses
+	var requestData formSubmission
+
+	// the raw body is kept for checking its signature
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, fc.maxBodyBytes))
+	if err != nil {
+		http.Error(w, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+
+	if err := json.Unmarshal(body, &requestData); err != nil {
+		writeFormProblems(w, []formProblem{{Field: "body", Message: "is not valid JSON: " + err.Error()}})
+		return
 	}
 
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		http.Error(w, "Error parsing request body: "+err.Error(), http.StatusBadRequest)
+	if requestData.FormId == "" {
+		writeFormProblems(w, []formProblem{{Field: "formId", Message: "is required"}})
 		return
 	}
 
-	projectID, err := primitive.ObjectIDFromHex(requestData.FormId)
+	// uploads below run under this deadline too
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	project, err := fc.findProject(ctx, requestData.FormId)
+	if err == mongo.ErrNoDocuments {
+		http.Error(w, fmt.Sprintf("Form %q is not registered to any project", requestData.FormId), http.StatusUnprocessableEntity)
+		return
+	}
+	if err != nil {
+		http.Error(w, "Failed to look up project for form", http.StatusInternalServerError)
+		log.Println("MongoDB Find project error:", err)
+		return
+	}
+	delivery, err := verifyWebhook(project, r.Header, body, time.Now())
 	if err != nil {
-		projectID = primitive.NewObjectID()
+		http.Error(w, "Invalid webhook signature: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+	if !projectAllows(project, models.ProjectAcceptingApplications) {
+		http.Error(w, "Project is not accepting applications", http.StatusConflict)
+		return
 	}
 
 	applicant := models.Applicant{
 		ID:            primitive.NewObjectID(),
-		ProjectID:     projectID,
-		Elo:          1000,
-		Wins:         0,
-		Losses:       0,
+		ProjectID:     project.ID,
+		Elo:           elo.InitialElo,
+		Wins:          0,
+		Losses:        0,
 		MatchesPlayed: []primitive.ObjectID{},
-		Timestamp:    requestData.Timestamp,
+		Timestamp:     requestData.Timestamp,
+	}
+
+	// everything is checked before any file is uploaded
+	answers, problems := parseFormResponses(requestData.Responses, formFields(project))
+	if len(problems) > 0 {
+		writeFormProblems(w, problems)
+		return
+	}
+
+	// files are mapped once they're uploaded
+	var files []formAnswer
+	for _, answer := range answers {
+		if answer.file != nil {
+			files = append(files, answer)
+			continue
+		}
+		applyFormAnswer(&applicant, answer.field, answer.value)
+	}
+	fc.store(ctx, w, applicant, files, delivery)
+}
+
+// storeApplicant uploads a checked submission's files and stores it, as a new applicant
+// or over the application it resubmits
+func (fc *FormResponseController) storeApplicant(ctx context.Context, w http.ResponseWriter, applicant models.Applicant, files []formAnswer, delivery webhookDelivery) {
+	projectID := applicant.ProjectID
+
+	// replays are turned away before uploading anything, the record written with the
+	// applicant is what stops two copies of a request racing each other
+	received, err := deliveryReceived(ctx, delivery.ID)
+	if err != nil {
+		http.Error(w, "Failed to check for earlier deliveries", http.StatusInternalServerError)
+		log.Println("Find webhook delivery error:", err)
+		return
+	}
+	if received {
+		http.Error(w, "Request was already received", http.StatusConflict)
+		return
 	}
 
 	bucket, err := gridfs.NewBucket(db.Client.Database("akpsi-ucsb"))
@@ -63,121 +153,765 @@ func (fc *FormResponseController) HandleFormResponse(w http.ResponseWriter, r *h
 		return
 	}
 
-	for _, resp := range requestData.Responses {
-		if err := processFormResponse(&applicant, resp, bucket); err != nil {
-			http.Error(w, err.Error(), http.StatusBadRequest)
+	for _, answer := range files {
+		fileInfo, err := uploadFile(answer.file, bucket)
+		if err != nil {
+			http.Error(w, "Error storing uploaded file", http.StatusInternalServerError)
+			log.Println("Form file upload error:", err)
 			return
 		}
+		applyFormAnswer(&applicant, answer.field, fileInfo)
 	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
-	defer cancel()
+	existing, kind, err := findDuplicate(ctx, applicant)
+	if err != nil {
+		http.Error(w, "Failed to check for duplicate applications", http.StatusInternalServerError)
+		log.Println("Find duplicate applicant error:", err)
+		return
+	}
+	switch kind {
+	case resubmission:
+		var replaced []*models.FileInfo
+		err := db.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+			var err error
+			if replaced, err = updateResubmission(sc, existing, applicant); err != nil {
+				return err
+			}
+			return recordDelivery(sc, delivery)
+		})
+		if err != nil {
+			if errors.Is(err, errWebhookReplayed) {
+				http.Error(w, "Request was already received", http.StatusConflict)
+				return
+			}
+			http.Error(w, "Failed to update existing application", http.StatusInternalServerError)
+			log.Println("Update resubmitted applicant error:", err)
+			return
+		}
+		for _, fileInfo := range replaced {
+			deleteFile(bucket, fileInfo)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"message": "Form response updated an existing application",
+			"id":      existing.ID,
+			"updated": true,
+		})
+		return
+	case possibleDuplicate:
+		applicant.DuplicateOf = &existing.ID
+	}
 
-	result, err := fc.collection.InsertOne(ctx, applicant)
+	err = db.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if _, err := fc.collection.InsertOne(sc, applicant); err != nil {
+			return err
+		}
+		return recordDelivery(sc, delivery)
+	})
+	if errors.Is(err, errWebhookReplayed) {
+		http.Error(w, "Request was already received", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Error inserting document: "+err.Error(), http.StatusInternalServerError)
 		return
 	}
+	pairPools.Invalidate(projectID)
+	setComparisonStatus(ctx, projectID, models.ComparisonsOpen)
+	if err := refreshProjectTotals(ctx, projectID); err != nil && err != mongo.ErrNoDocuments {
+		log.Println("Refresh project totals error:", err)
+	}
 
 	w.Header().Set("Content-Type", "application/json")
 	w.WriteHeader(http.StatusCreated)
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"message": "Form response received successfully",
-		"id":     result.InsertedID,
+		"message":     "Form response received successfully",
+		"id":          applicant.ID,
+		"duplicateOf": applicant.DuplicateOf,
 	})
 
 	prettyJSON, _ := json.MarshalIndent(applicant, "", "    ")
 	fmt.Printf("Application Received:\n%s\n", string(prettyJSON))
 }
 
-func processFormResponse(applicant *models.Applicant, resp models.Response, bucket *gridfs.Bucket) error {
-	switch resp.Question {
-	case "firstName":
-		if strVal, ok := resp.Answer.(string); ok {
-			applicant.FirstName = strVal
+// projectForForm finds the project a form is registered to. Forms set up before
+// registration existed send the project's ID itself, which is still accepted
+func projectForForm(ctx context.Context, formID string) (models.Project, error) {
+	projects := db.GetCollection("projects")
+
+	var project models.Project
+	err := projects.FindOne(ctx, bson.M{"formIds": formID}).Decode(&project)
+	if err != mongo.ErrNoDocuments {
+		return project, err
+	}
+
+	projectID, parseErr := primitive.ObjectIDFromHex(formID)
+	if parseErr != nil {
+		return project, mongo.ErrNoDocuments
+	}
+	err = projects.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	return project, err
+}
+
+// defaultFormSchema is how forms are read for projects that haven't defined a schema
+var defaultFormSchema = []models.FormField{
+	{Question: "firstName", Field: "firstName", Type: models.FieldTypeString},
+	{Question: "lastName", Field: "lastName", Type: models.FieldTypeString},
+	{Question: "major", Field: "major", Type: models.FieldTypeString},
+	{Question: "year", Field: "year", Type: models.FieldTypeString},
+	{Question: "email", Field: "email", Type: models.FieldTypeString},
+	{Question: "coverLetter", Field: "coverLetter", Type: models.FieldTypeFile},
+	{Question: "resume", Field: "resume", Type: models.FieldTypeFile},
+	{Question: "image", Field: "image", Type: models.FieldTypeFile},
+}
+
+// the type each field built into models.Applicant has to be mapped with
+var applicantFieldTypes = map[string]string{
+	"firstName":   models.FieldTypeString,
+	"lastName":    models.FieldTypeString,
+	"major":       models.FieldTypeString,
+	"year":        models.FieldTypeString,
+	"email":       models.FieldTypeString,
+	"coverLetter": models.FieldTypeFile,
+	"resume":      models.FieldTypeFile,
+	"image":       models.FieldTypeFile,
+}
+
+// formFields indexes a project's form schema by question
+func formFields(project models.Project) map[string]models.FormField {
+	schema := project.FormSchema
+	if len(schema) == 0 {
+		schema = defaultFormSchema
+	}
+
+	fields := make(map[string]models.FormField, len(schema))
+	for _, field := range schema {
+		fields[field.Question] = field
+	}
+	return fields
+}
+
+// validateFormSchema checks a schema before it is saved on a project
+func validateFormSchema(schema []models.FormField) error {
+	questions := make(map[string]bool, len(schema))
+	names := make(map[string]bool, len(schema))
+	for _, field := range schema {
+		if field.Question == "" || field.Field == "" {
+			return fmt.Errorf("every form field needs a question and a field")
 		}
-	case "lastName":
-		if strVal, ok := resp.Answer.(string); ok {
-			applicant.LastName = strVal
+		if questions[field.Question] {
+			return fmt.Errorf("question %q is mapped twice", field.Question)
 		}
-	case "major":
-		if strVal, ok := resp.Answer.(string); ok {
-			applicant.Major = strVal
+		if names[field.Field] {
+			return fmt.Errorf("field %q is mapped twice", field.Field)
 		}
-	case "year":
-		if strVal, ok := resp.Answer.(string); ok {
-			applicant.Year = strVal
+		questions[field.Question], names[field.Field] = true, true
+
+		switch field.Type {
+		case models.FieldTypeString, models.FieldTypeNumber, models.FieldTypeBoolean, models.FieldTypeList, models.FieldTypeFile:
+		default:
+			return fmt.Errorf("field %q has unknown type %q", field.Field, field.Type)
 		}
-	case "coverLetter", "resume", "image":
-		if err := processFileUpload(applicant, resp, bucket); err != nil {
-			return err
+		if fieldType, ok := applicantFieldTypes[field.Field]; ok && fieldType != field.Type {
+			return fmt.Errorf("field %q must be of type %s", field.Field, fieldType)
+		}
+		if answerKey(field.Field) != field.Field {
+			return fmt.Errorf("field %q can't contain '.' or '$'", field.Field)
 		}
 	}
 	return nil
 }
 
-func processFileUpload(applicant *models.Applicant, resp models.Response, bucket *gridfs.Bucket) error {
-	answer, ok := resp.Answer.(map[string]interface{})
-	if !ok || answer["type"] != "file" {
-		return fmt.Errorf("invalid file data format")
+// duplicate helper functions
+
+const (
+	notDuplicate = iota
+	// same name but different enough answers, inserted and flagged for an admin to merge
+	possibleDuplicate
+	// the same person submitting again, their existing application is updated
+	resubmission
+)
+
+const (
+	// answers at least this similar, along with the name, make a submission a resubmission
+	resubmissionSimilarity = 0.8
+	// a name and a couple of answers like major and year are shared by different people,
+	// it takes at least this many compared answers to tell them apart
+	minComparedAnswers = 4
+)
+
+// findDuplicate looks for an earlier application from the same person in the project,
+// see duplicateKind. A resubmission wins over a possible duplicate
+func findDuplicate(ctx context.Context, applicant models.Applicant) (models.Applicant, int, error) {
+	cursor, err := db.GetCollection("applicants").Find(ctx,
+		bson.M{"project_id": applicant.ProjectID},
+		options.Find().SetSort(bson.D{{Key: "_id", Value: 1}}),
+	)
+	if err != nil {
+		return models.Applicant{}, notDuplicate, err
+	}
+	var candidates []models.Applicant
+	if err = cursor.All(ctx, &candidates); err != nil {
+		return models.Applicant{}, notDuplicate, err
+	}
+
+	var possible *models.Applicant
+	for i, candidate := range candidates {
+		switch duplicateKind(candidate, applicant) {
+		case resubmission:
+			return candidate, resubmission, nil
+		case possibleDuplicate:
+			if possible == nil {
+				possible = &candidates[i]
+			}
+		}
+	}
+
+	if possible != nil {
+		return *possible, possibleDuplicate, nil
+	}
+	return models.Applicant{}, notDuplicate, nil
+}
+
+// duplicateKind compares a submission with an existing application. A matching email
+// is always the same person and a different one never is. Otherwise a matching name is
+// only a resubmission when enough other answers agree, and is flagged when they don't
+func duplicateKind(existing, submitted models.Applicant) int {
+	existingEmail, submittedEmail := normalizeAnswer(existing.Email), normalizeAnswer(submitted.Email)
+	if submittedEmail != "" && existingEmail == submittedEmail {
+		return resubmission
+	}
+	if submittedEmail != "" && existingEmail != "" {
+		return notDuplicate
+	}
+
+	if normalizeAnswer(existing.FirstName) != normalizeAnswer(submitted.FirstName) ||
+		normalizeAnswer(existing.LastName) != normalizeAnswer(submitted.LastName) ||
+		normalizeAnswer(submitted.FirstName+submitted.LastName) == "" {
+		return notDuplicate
+	}
+	if similarity, compared := answerSimilarity(existing, submitted); compared >= minComparedAnswers && similarity >= resubmissionSimilarity {
+		return resubmission
+	}
+	return possibleDuplicate
+}
+
+// normalizeAnswer ignores case and spacing when comparing answers
+func normalizeAnswer(answer string) string {
+	return strings.Join(strings.Fields(strings.ToLower(answer)), " ")
+}
+
+// answerSimilarity is the share of answers two applications have in common, files left
+// out, along with how many answers were compared
+func answerSimilarity(a, b models.Applicant) (float64, int) {
+	answersA := map[string]interface{}{"major": a.Major, "year": a.Year}
+	answersB := map[string]interface{}{"major": b.Major, "year": b.Year}
+	for _, side := range []struct {
+		from map[string]interface{}
+		to   map[string]interface{}
+	}{{a.Answers, answersA}, {b.Answers, answersB}} {
+		for key, value := range side.from {
+			if _, ok := answerFile(value); !ok {
+				side.to[key] = value
+			}
+		}
+	}
+
+	compared, same := 0, 0
+	for key, valueA := range answersA {
+		valueB, ok := answersB[key]
+		if !ok {
+			compared++
+			continue
+		}
+		strA, strB := normalizeAnswer(fmt.Sprint(valueA)), normalizeAnswer(fmt.Sprint(valueB))
+		if strA == "" && strB == "" {
+			continue
+		}
+		compared++
+		if strA == strB {
+			same++
+		}
+	}
+	for key := range answersB {
+		if _, ok := answersA[key]; !ok {
+			compared++
+		}
+	}
+
+	if compared == 0 {
+		return 0, 0
+	}
+	return float64(same) / float64(compared), compared
+}
+
+// updateResubmission replaces an application's answers and files with the ones from a
+// resubmission, keeping its ratings and match history. Returns the files it replaced,
+// to be deleted once the update has committed
+func updateResubmission(ctx context.Context, existing, resubmitted models.Applicant) ([]*models.FileInfo, error) {
+	// files left out of the resubmission stay as they were
+	var replaced []*models.FileInfo
+	answers := existing.Answers
+	if answers == nil {
+		answers = make(map[string]interface{})
+	}
+	for key, value := range resubmitted.Answers {
+		if fileInfo, ok := answerFile(answers[key]); ok {
+			replaced = append(replaced, fileInfo)
+		}
+		answers[key] = value
+	}
+
+	set := bson.M{
+		"firstName": resubmitted.FirstName,
+		"lastName":  resubmitted.LastName,
+		"major":     resubmitted.Major,
+		"year":      resubmitted.Year,
+		"timestamp": resubmitted.Timestamp,
+		"answers":   answers,
+	}
+	if resubmitted.Email != "" {
+		set["email"] = resubmitted.Email
+	}
+
+	files := []struct {
+		field          string
+		previous, next *models.FileInfo
+	}{
+		{"resume", existing.Resume, resubmitted.Resume},
+		{"coverLetter", existing.CoverLetter, resubmitted.CoverLetter},
+		{"image", existing.Image, resubmitted.Image},
+	}
+	for _, file := range files {
+		if file.next != nil {
+			set[file.field] = file.next
+			replaced = append(replaced, file.previous)
+		}
+	}
+
+	if _, err := db.GetCollection("applicants").UpdateOne(ctx, bson.M{"_id": existing.ID}, bson.M{"$set": set}); err != nil {
+		return nil, err
+	}
+	return replaced, nil
+}
+
+// webhook helper functions
+
+// the form script signs each submission with the project's webhook secret:
+// X-Webhook-Signature is "sha256=" followed by the hex HMAC-SHA256 of
+// "<X-Webhook-Timestamp>.<body>", the timestamp being unix seconds
+const (
+	webhookTimestampHeader = "X-Webhook-Timestamp"
+	webhookSignatureHeader = "X-Webhook-Signature"
+	// how far a submission's timestamp may be from the server's clock
+	webhookTolerance = 5 * time.Minute
+	// submissions carry their files inline
+	maxFormBodyBytes = 64 << 20
+)
+
+var (
+	errWebhookUnsigned  = errors.New("missing signature or timestamp")
+	errWebhookNoSecret  = errors.New("project has no webhook secret")
+	errWebhookStale     = errors.New("timestamp is too old or too far ahead")
+	errWebhookSignature = errors.New("signature does not match")
+	errWebhookReplayed  = errors.New("request was already received")
+)
+
+// webhookDelivery is the record of an accepted submission's signature, so the same
+// request can't be accepted twice. Once the timestamp falls outside the tolerance the
+// request is rejected anyway, so the record only needs to outlive that
+type webhookDelivery struct {
+	ID         string             `bson:"_id"`
+	ProjectID  primitive.ObjectID `bson:"project_id"`
+	ReceivedAt time.Time          `bson:"received_at"`
+	ExpiresAt  time.Time          `bson:"expires_at"`
+}
+
+// verifyWebhook checks a submission was signed with one of the project's active secrets
+// within the tolerance, and returns the delivery to record once it has been stored
+func verifyWebhook(project models.Project, header http.Header, body []byte, now time.Time) (webhookDelivery, error) {
+	timestamp := header.Get(webhookTimestampHeader)
+	signature := header.Get(webhookSignatureHeader)
+	if timestamp == "" || signature == "" {
+		return webhookDelivery{}, errWebhookUnsigned
+	}
+
+	unix, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return webhookDelivery{}, errWebhookStale
+	}
+	sentAt := time.Unix(unix, 0)
+	if sentAt.Before(now.Add(-webhookTolerance)) || sentAt.After(now.Add(webhookTolerance)) {
+		return webhookDelivery{}, errWebhookStale
 	}
 
-	fileInfo, err := uploadFile(answer, bucket)
+	mac, err := hex.DecodeString(strings.TrimPrefix(signature, "sha256="))
 	if err != nil {
-		return err
+		return webhookDelivery{}, errWebhookSignature
 	}
 
-	switch resp.Question {
-	case "coverLetter":
-		applicant.CoverLetter = fileInfo
-	case "resume":
-		applicant.Resume = fileInfo
-	case "image":
-		applicant.Image = fileInfo
+	secrets := activeWebhookSecrets(project, now)
+	if len(secrets) == 0 {
+		return webhookDelivery{}, errWebhookNoSecret
 	}
-	return nil
+	matched := false
+	for _, secret := range secrets {
+		if hmac.Equal(mac, signWebhook(secret.Secret, timestamp, body)) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return webhookDelivery{}, errWebhookSignature
+	}
+
+	return webhookDelivery{
+		ID:         hex.EncodeToString(mac),
+		ProjectID:  project.ID,
+		ReceivedAt: now,
+		ExpiresAt:  sentAt.Add(webhookTolerance),
+	}, nil
+}
+
+// deliveryReceived reports whether a submission has been accepted before
+func deliveryReceived(ctx context.Context, id string) (bool, error) {
+	err := db.GetCollection("webhook_deliveries").FindOne(ctx, bson.M{"_id": id}).Err()
+	if err == mongo.ErrNoDocuments {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// recordDelivery stores a delivery along with the applicant it created or updated, run
+// it in the same transaction so a failed submission can be retried
+func recordDelivery(sc mongo.SessionContext, delivery webhookDelivery) error {
+	_, err := db.GetCollection("webhook_deliveries").InsertOne(sc, delivery)
+	if mongo.IsDuplicateKeyError(err) {
+		return errWebhookReplayed
+	}
+	return err
+}
+
+func signWebhook(secret, timestamp string, body []byte) []byte {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return mac.Sum(nil)
+}
+
+// activeWebhookSecrets are the secrets a submission may be signed with right now,
+// the newest and any rotated out ones still in their grace period
+func activeWebhookSecrets(project models.Project, now time.Time) []models.WebhookSecret {
+	var active []models.WebhookSecret
+	for _, secret := range project.WebhookSecrets {
+		if secret.ExpiresAt == nil || secret.ExpiresAt.After(now) {
+			active = append(active, secret)
+		}
+	}
+	return active
+}
+
+// formProblem is one thing wrong with a submitted form response, Field is the path
+// into the request body
+type formProblem struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+func writeFormProblems(w http.ResponseWriter, problems []formProblem) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusBadRequest)
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"error":    "Invalid form response",
+		"problems": problems,
+	})
+}
+
+// formAnswer is a checked response, ready to be stored under field
+type formAnswer struct {
+	field models.FormField
+	value interface{}
+	file  *fileAnswer
+}
+
+type fileAnswer struct {
+	data        []byte
+	fileName    string
+	mimeType    string
+	driveFileID string
+}
+
+// parseFormResponses checks every response against the project's schema, collecting
+// all problems rather than stopping at the first
+func parseFormResponses(responses []models.Response, fields map[string]models.FormField) ([]formAnswer, []formProblem) {
+	answers := make([]formAnswer, 0, len(responses))
+	var problems []formProblem
+
+	for i, resp := range responses {
+		path := fmt.Sprintf("responses[%d]", i)
+		if resp.Question == "" {
+			problems = append(problems, formProblem{Field: path + ".question", Message: "is required"})
+			continue
+		}
+
+		field, ok := fields[resp.Question]
+		if !ok {
+			// unmapped answers are kept as submitted, except files, which are uploaded like
+			// mapped ones rather than stored inline
+			field = models.FormField{Question: resp.Question, Field: answerKey(resp.Question)}
+			if !isFileAnswer(resp.Answer) {
+				answers = append(answers, formAnswer{field: field, value: resp.Answer})
+				continue
+			}
+			field.Type = models.FieldTypeFile
+		}
+
+		if field.Type == models.FieldTypeFile {
+			file, fileProblems := parseFileAnswer(resp.Answer)
+			for _, problem := range fileProblems {
+				problem.Field = path + ".answer" + problem.Field
+				problems = append(problems, problem)
+			}
+			answers = append(answers, formAnswer{field: field, file: file})
+			continue
+		}
+
+		value, err := convertAnswer(resp.Answer, field.Type)
+		if err != nil {
+			problems = append(problems, formProblem{Field: path + ".answer", Message: "must be a " + field.Type})
+			continue
+		}
+		answers = append(answers, formAnswer{field: field, value: value})
+	}
+
+	return answers, problems
 }
 
-func uploadFile(answer map[string]interface{}, bucket *gridfs.Bucket) (*models.FileInfo, error) {
-	dataStr, ok := answer["data"].(string)
+// parseFileAnswer reads a file answer as sent by the form script:
+// {"type": "file", "data": base64, "filename": ..., "mimeType": ..., "fileId": [driveId]}
+func parseFileAnswer(answer interface{}) (*fileAnswer, []formProblem) {
+	fields, ok := answer.(map[string]interface{})
 	if !ok {
-		return nil, fmt.Errorf("invalid file data format")
+		return nil, []formProblem{{Message: "must be a file object"}}
+	}
+
+	var problems []formProblem
+	if fields["type"] != "file" {
+		problems = append(problems, formProblem{Field: ".type", Message: `must be "file"`})
+	}
+
+	file := &fileAnswer{}
+	if data, ok := fields["data"].(string); !ok || data == "" {
+		problems = append(problems, formProblem{Field: ".data", Message: "is required"})
+	} else if decoded, err := base64.StdEncoding.DecodeString(data); err != nil {
+		problems = append(problems, formProblem{Field: ".data", Message: "is not valid base64"})
+	} else {
+		file.data = decoded
+	}
+
+	if fileName, ok := fields["filename"].(string); !ok || fileName == "" {
+		problems = append(problems, formProblem{Field: ".filename", Message: "is required"})
+	} else {
+		file.fileName = fileName
+	}
+
+	if mimeType, ok := fields["mimeType"].(string); !ok || mimeType == "" {
+		problems = append(problems, formProblem{Field: ".mimeType", Message: "is required"})
+	} else {
+		file.mimeType = mimeType
+	}
+
+	// the Drive ID is optional, and arrives either alone or as the first of a list
+	switch driveID := fields["fileId"].(type) {
+	case nil:
+	case string:
+		file.driveFileID = driveID
+	case []interface{}:
+		if len(driveID) > 0 {
+			if id, ok := driveID[0].(string); ok {
+				file.driveFileID = id
+				break
+			}
+		}
+		problems = append(problems, formProblem{Field: ".fileId", Message: "must be a list of strings"})
+	default:
+		problems = append(problems, formProblem{Field: ".fileId", Message: "must be a string or a list of strings"})
+	}
+
+	if len(problems) > 0 {
+		return nil, problems
 	}
+	return file, nil
+}
 
-	fileData, err := base64.StdEncoding.DecodeString(dataStr)
+// applyFormAnswer stores a checked answer on the applicant, files once they're uploaded
+func applyFormAnswer(applicant *models.Applicant, field models.FormField, value interface{}) {
+	str, _ := value.(string)
+	switch field.Field {
+	case "firstName":
+		applicant.FirstName = str
+	case "lastName":
+		applicant.LastName = str
+	case "major":
+		applicant.Major = str
+	case "year":
+		applicant.Year = str
+	case "email":
+		applicant.Email = str
+	case "coverLetter", "resume", "image":
+		fileInfo, _ := value.(*models.FileInfo)
+		switch field.Field {
+		case "coverLetter":
+			applicant.CoverLetter = fileInfo
+		case "resume":
+			applicant.Resume = fileInfo
+		case "image":
+			applicant.Image = fileInfo
+		}
+	default:
+		setAnswer(applicant, field.Field, value)
+	}
+}
+
+// convertAnswer reads an answer as the given field type. Form tools send most answers
+// as text, so numbers and booleans are also parsed from strings
+func convertAnswer(answer interface{}, fieldType string) (interface{}, error) {
+	switch fieldType {
+	case models.FieldTypeString:
+		switch v := answer.(type) {
+		case string:
+			return v, nil
+		case float64, bool:
+			return fmt.Sprint(v), nil
+		}
+	case models.FieldTypeNumber:
+		switch v := answer.(type) {
+		case float64:
+			return v, nil
+		case string:
+			return strconv.ParseFloat(strings.TrimSpace(v), 64)
+		}
+	case models.FieldTypeBoolean:
+		switch v := answer.(type) {
+		case bool:
+			return v, nil
+		case string:
+			switch strings.ToLower(strings.TrimSpace(v)) {
+			case "true", "yes", "y":
+				return true, nil
+			case "false", "no", "n":
+				return false, nil
+			}
+		}
+	case models.FieldTypeList:
+		switch v := answer.(type) {
+		case string:
+			return []string{v}, nil
+		case []interface{}:
+			list := make([]string, 0, len(v))
+			for _, item := range v {
+				str, ok := item.(string)
+				if !ok {
+					return nil, fmt.Errorf("list items must be strings")
+				}
+				list = append(list, str)
+			}
+			return list, nil
+		}
+	}
+	return nil, fmt.Errorf("can't read %T as %s", answer, fieldType)
+}
+
+func setAnswer(applicant *models.Applicant, key string, value interface{}) {
+	if applicant.Answers == nil {
+		applicant.Answers = make(map[string]interface{})
+	}
+	applicant.Answers[key] = value
+}
+
+// isFileAnswer reports whether an answer is a file upload as sent by the form script
+func isFileAnswer(answer interface{}) bool {
+	fields, ok := answer.(map[string]interface{})
+	return ok && fields["type"] == "file"
+}
+
+// answerFile reads a file stored among an applicant's answers, which come back from the
+// database as plain documents
+func answerFile(value interface{}) (*models.FileInfo, bool) {
+	switch v := value.(type) {
+	case *models.FileInfo:
+		return v, true
+	case primitive.D, primitive.M, map[string]interface{}:
+	default:
+		return nil, false
+	}
+
+	raw, err := bson.Marshal(value)
 	if err != nil {
-		return nil, fmt.Errorf("error decoding file data: %v", err)
+		return nil, false
 	}
+	var fileInfo models.FileInfo
+	if bson.Unmarshal(raw, &fileInfo) != nil || fileInfo.FileID == "" || fileInfo.UniqueName == "" {
+		return nil, false
+	}
+	return &fileInfo, true
+}
 
+// applicantFiles lists every file an applicant uploaded, the ones in their answers too
+func applicantFiles(applicant models.Applicant) []*models.FileInfo {
+	files := []*models.FileInfo{applicant.Image, applicant.CoverLetter, applicant.Resume}
+	for _, value := range applicant.Answers {
+		if fileInfo, ok := answerFile(value); ok {
+			files = append(files, fileInfo)
+		}
+	}
+	return files
+}
+
+// answerKey makes a question usable as a document key, which can't contain dots or
+// start with $
+func answerKey(question string) string {
+	return strings.NewReplacer(".", "_", "$", "_").Replace(question)
+}
+
+func uploadFile(file *fileAnswer, bucket *gridfs.Bucket) (*models.FileInfo, error) {
 	timestamp := time.Now().Unix()
-	uniqueFileName := fmt.Sprintf("%d_%s", timestamp, answer["filename"].(string))
+	uniqueFileName := fmt.Sprintf("%d_%s", timestamp, file.fileName)
 
-	fileID, err := uploadToGridFS(bucket, uniqueFileName, fileData)
+	fileID, err := uploadToGridFS(bucket, uniqueFileName, file.mimeType, file.data)
 	if err != nil {
 		return nil, err
 	}
 
 	return &models.FileInfo{
 		FileID:      fileID.Hex(),
-		FileName:    answer["filename"].(string),
-		MimeType:    answer["mimeType"].(string),
-		DriveFileID: answer["fileId"].([]interface{})[0].(string),
+		FileName:    file.fileName,
+		MimeType:    file.mimeType,
+		DriveFileID: file.driveFileID,
 		UniqueName:  uniqueFileName,
 		UploadedAt:  time.Now(),
 	}, nil
 }
 
-func uploadToGridFS(bucket *gridfs.Bucket, filename string, data []byte) (primitive.ObjectID, error) {
-	uploadStream, err := bucket.OpenUploadStream(filename)
+func uploadToGridFS(bucket *gridfs.Bucket, filename, contentType string, data []byte) (primitive.ObjectID, error) {
+	fileID := primitive.NewObjectID()
+	// kept with the file so it can be served without looking up its applicant
+	opts := options.GridFSUpload().SetMetadata(bson.M{"contentType": contentType})
+	uploadStream, err := bucket.OpenUploadStreamWithID(fileID, filename, opts)
 	if err != nil {
 		return primitive.NilObjectID, fmt.Errorf("error opening upload stream: %v", err)
 	}
-	defer uploadStream.Close()
 
 	_, err = uploadStream.Write(data)
 	if err != nil {
+		uploadStream.Abort()
 		return primitive.NilObjectID, fmt.Errorf("error writing to stream: %v", err)
 	}
 
-	return uploadStream.FileID.(primitive.ObjectID), nil
+	// the file is only complete once the stream is closed
+	if err := uploadStream.Close(); err != nil {
+		return primitive.NilObjectID, fmt.Errorf("error closing upload stream: %v", err)
+	}
+	return fileID, nil
 }
\ No newline at end of file
@@ -0,0 +1,364 @@
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"backend/models"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+func problemFields(problems []formProblem) []string {
+	fields := make([]string, 0, len(problems))
+	for _, problem := range problems {
+		fields = append(fields, problem.Field)
+	}
+	return fields
+}
+
+func TestParseFileAnswer(t *testing.T) {
+	valid := func() map[string]interface{} {
+		return map[string]interface{}{
+			"type":     "file",
+			"data":     "aGVsbG8=",
+			"filename": "resume.pdf",
+			"mimeType": "application/pdf",
+			"fileId":   []interface{}{"drive-id"},
+		}
+	}
+	with := func(key string, value interface{}) map[string]interface{} {
+		answer := valid()
+		if value == nil {
+			delete(answer, key)
+		} else {
+			answer[key] = value
+		}
+		return answer
+	}
+
+	tests := []struct {
+		name   string
+		answer interface{}
+		want   []string
+	}{
+		{"valid", valid(), []string{}},
+		{"fileId as string", with("fileId", "drive-id"), []string{}},
+		{"no fileId", with("fileId", nil), []string{}},
+		{"not an object", "resume.pdf", []string{""}},
+		{"null", nil, []string{""}},
+		{"wrong type", with("type", "text"), []string{".type"}},
+		{"no data", with("data", nil), []string{".data"}},
+		{"data not a string", with("data", 12.0), []string{".data"}},
+		{"data not base64", with("data", "not base64!"), []string{".data"}},
+		{"no filename", with("filename", nil), []string{".filename"}},
+		{"empty filename", with("filename", ""), []string{".filename"}},
+		{"filename not a string", with("filename", []interface{}{"a"}), []string{".filename"}},
+		{"no mimeType", with("mimeType", nil), []string{".mimeType"}},
+		{"empty fileId list", with("fileId", []interface{}{}), []string{".fileId"}},
+		{"fileId list of numbers", with("fileId", []interface{}{1.0}), []string{".fileId"}},
+		{"fileId object", with("fileId", map[string]interface{}{}), []string{".fileId"}},
+		{"everything missing", map[string]interface{}{}, []string{".type", ".data", ".filename", ".mimeType"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			file, problems := parseFileAnswer(tt.answer)
+			if got := problemFields(problems); !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("problems at %q, want %q", got, tt.want)
+			}
+			if (file == nil) != (len(tt.want) > 0) {
+				t.Fatalf("file = %v with %d problems", file, len(problems))
+			}
+		})
+	}
+}
+
+func TestConvertAnswer(t *testing.T) {
+	tests := []struct {
+		answer    interface{}
+		fieldType string
+		want      interface{}
+		wantErr   bool
+	}{
+		{"Economics", models.FieldTypeString, "Economics", false},
+		{3.0, models.FieldTypeString, "3", false},
+		{true, models.FieldTypeString, "true", false},
+		{nil, models.FieldTypeString, nil, true},
+		{[]interface{}{"a"}, models.FieldTypeString, nil, true},
+		{3.5, models.FieldTypeNumber, 3.5, false},
+		{" 3.5 ", models.FieldTypeNumber, 3.5, false},
+		{"three", models.FieldTypeNumber, nil, true},
+		{true, models.FieldTypeNumber, nil, true},
+		{false, models.FieldTypeBoolean, false, false},
+		{"Yes", models.FieldTypeBoolean, true, false},
+		{"n", models.FieldTypeBoolean, false, false},
+		{"maybe", models.FieldTypeBoolean, nil, true},
+		{"a", models.FieldTypeList, []string{"a"}, false},
+		{[]interface{}{"a", "b"}, models.FieldTypeList, []string{"a", "b"}, false},
+		{[]interface{}{"a", 1.0}, models.FieldTypeList, nil, true},
+		{"a", "unknown", nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := convertAnswer(tt.answer, tt.fieldType)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("convertAnswer(%#v, %s) error = %v, want error %v", tt.answer, tt.fieldType, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("convertAnswer(%#v, %s) = %#v, want %#v", tt.answer, tt.fieldType, got, tt.want)
+		}
+	}
+}
+
+func TestParseFormResponsesPaths(t *testing.T) {
+	fields := formFields(models.Project{FormSchema: []models.FormField{
+		{Question: "Graduation year", Field: "gradYear", Type: models.FieldTypeNumber},
+		{Question: "Resume", Field: "resume", Type: models.FieldTypeFile},
+	}})
+	responses := []models.Response{
+		{Question: "Graduation year", Answer: "soon"},
+		{Question: "", Answer: "x"},
+		{Question: "Resume", Answer: map[string]interface{}{"type": "file", "filename": "cv.pdf", "mimeType": "application/pdf"}},
+		{Question: "Transcript", Answer: map[string]interface{}{"type": "file", "data": "aGk=", "mimeType": "application/pdf"}},
+		{Question: "Why us?", Answer: "Because"},
+	}
+
+	answers, problems := parseFormResponses(responses, fields)
+	want := []string{
+		"responses[0].answer",
+		"responses[1].question",
+		"responses[2].answer.data",
+		"responses[3].answer.filename",
+	}
+	if got := problemFields(problems); !reflect.DeepEqual(got, want) {
+		t.Fatalf("problems at %q, want %q", got, want)
+	}
+	if len(answers) != 3 {
+		t.Fatalf("got %d answers, want the 3 that could be read", len(answers))
+	}
+}
+
+// applicantValue is where applyFormAnswer puts a field on an applicant
+func applicantValue(applicant models.Applicant, field string) interface{} {
+	switch field {
+	case "firstName":
+		return applicant.FirstName
+	case "lastName":
+		return applicant.LastName
+	case "major":
+		return applicant.Major
+	case "year":
+		return applicant.Year
+	case "email":
+		return applicant.Email
+	}
+	return applicant.Answers[field]
+}
+
+func FuzzHandleFormResponse(f *testing.F) {
+	seeds := []string{
+		`{"formId":"f","responses":[{"question":"firstName","answer":"Ada"}]}`,
+		`{"formId":"legacy","responses":[{"question":"firstName","answer":"Ada"},{"question":"year","answer":2}]}`,
+		`{"formId":"unknown","responses":[{"question":"firstName","answer":"Ada"}]}`,
+		`{"formId":"","responses":[]}`,
+		`{"formId":"f","responses":[{"question":"resume","answer":{"type":"file","data":"aGk=","filename":"a.pdf","mimeType":"application/pdf","fileId":["x"]}}]}`,
+		`{"formId":"f","responses":[{"question":"resume","answer":{"type":"file","data":"aGk=","filename":"a.pdf","mimeType":"application/pdf","fileId":[]}}]}`,
+		`{"formId":"f","responses":[{"question":"resume","answer":{"type":"file","data":"aGk=","filename":"a.pdf","mimeType":"application/pdf","fileId":[null]}}]}`,
+		`{"formId":"f","responses":[{"question":"resume","answer":{"type":"file","data":"aGk=","filename":"a.pdf","mimeType":"application/pdf","fileId":{"id":1}}}]}`,
+		`{"formId":"f","responses":[{"question":"resume","answer":{"type":"file","data":"aGk=","filename":null,"mimeType":"application/pdf"}}]}`,
+		`{"formId":"f","responses":[{"question":"resume","answer":{"type":"file","data":"aGk=","filename":["a.pdf"],"mimeType":"application/pdf"}}]}`,
+		`{"formId":"legacy","responses":[{"question":"image","answer":{"type":"file","data":12,"filename":"a.png","mimeType":"image/png"}}]}`,
+		`{"formId":"legacy","responses":[{"question":"image","answer":{"type":"file","data":"%%%","filename":"a.png","mimeType":"image/png"}}]}`,
+		`{"formId":"f","responses":[{"question":"Transcript","answer":{"type":"file"}}]}`,
+		`{"formId":"f","responses":[{"question":"resume","answer":"a.pdf"},{"question":"year","answer":[1,2]}]}`,
+		`{"formId":"f","responses":[{"question":"year","answer":"2026"},{"question":"Clubs","answer":["Chess"]},{"question":"Transfer?","answer":"no"}]}`,
+		`{"formId":"f","responses":[{"question":"Why $us.","answer":{"a":1}},{"question":"Why _us_","answer":null}]}`,
+		`{"formId":"f","responses":[{"question":"","answer":null}]}`,
+		`{"formId":"f","responses":null}`,
+		`{"formId":"f","responses":[{"question":"Why us?","answer":"` + strings.Repeat("a", 2000) + `"}]}`,
+		`{}`,
+		`not json`,
+	}
+	for _, seed := range seeds {
+		f.Add([]byte(seed))
+	}
+
+	const secret = "fuzz-secret"
+	projects := map[string]models.Project{
+		"f": {
+			ID:             primitive.NewObjectID(),
+			Status:         models.ProjectAcceptingApplications,
+			WebhookSecrets: []models.WebhookSecret{{Secret: secret}},
+			FormSchema: []models.FormField{
+				{Question: "resume", Field: "resume", Type: models.FieldTypeFile},
+				{Question: "year", Field: "gradYear", Type: models.FieldTypeNumber},
+				{Question: "Clubs", Field: "clubs", Type: models.FieldTypeList},
+				{Question: "Transfer?", Field: "transfer", Type: models.FieldTypeBoolean},
+			},
+		},
+		// no schema, read with the default one
+		"legacy": {
+			ID:             primitive.NewObjectID(),
+			Status:         models.ProjectAcceptingApplications,
+			WebhookSecrets: []models.WebhookSecret{{Secret: secret}},
+		},
+	}
+	const maxBody = 1 << 10
+
+	f.Fuzz(func(t *testing.T, body []byte) {
+		var (
+			stored bool
+			got    models.Applicant
+			files  []formAnswer
+		)
+		fc := &FormResponseController{
+			maxBodyBytes: maxBody,
+			findProject: func(ctx context.Context, formID string) (models.Project, error) {
+				project, ok := projects[formID]
+				if !ok {
+					return models.Project{}, mongo.ErrNoDocuments
+				}
+				return project, nil
+			},
+			store: func(ctx context.Context, w http.ResponseWriter, applicant models.Applicant, answers []formAnswer, delivery webhookDelivery) {
+				stored, got, files = true, applicant, answers
+				w.WriteHeader(http.StatusCreated)
+			},
+		}
+
+		timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+		r := httptest.NewRequest(http.MethodPost, "/formResponseListener", bytes.NewReader(body))
+		r.Header.Set(webhookTimestampHeader, timestamp)
+		r.Header.Set(webhookSignatureHeader, "sha256="+hex.EncodeToString(signWebhook(secret, timestamp, body)))
+		w := httptest.NewRecorder()
+		fc.HandleFormResponse(w, r)
+
+		wantStatus := func(status int) {
+			t.Helper()
+			if w.Code != status {
+				t.Fatalf("status %d, want %d: %s", w.Code, status, w.Body)
+			}
+		}
+
+		if len(body) > maxBody {
+			wantStatus(http.StatusRequestEntityTooLarge)
+			return
+		}
+		var submission formSubmission
+		if err := json.Unmarshal(body, &submission); err != nil || submission.FormId == "" {
+			wantStatus(http.StatusBadRequest)
+			return
+		}
+		project, ok := projects[submission.FormId]
+		if !ok {
+			wantStatus(http.StatusUnprocessableEntity)
+			return
+		}
+		if !stored {
+			wantStatus(http.StatusBadRequest)
+			return
+		}
+		wantStatus(http.StatusCreated)
+		if got.ProjectID != project.ID {
+			t.Fatalf("applicant stored under project %s, form is registered to %s", got.ProjectID.Hex(), project.ID.Hex())
+		}
+
+		// every answer ends up under its field, the last one wins when several share it,
+		// and files are left for storing
+		fields := formFields(project)
+		want := make(map[string]interface{})
+		wantFiles := make(map[string]bool)
+		for _, resp := range submission.Responses {
+			field, mapped := fields[resp.Question]
+			if !mapped {
+				field = models.FormField{Field: answerKey(resp.Question)}
+				if isFileAnswer(resp.Answer) {
+					field.Type = models.FieldTypeFile
+				}
+			}
+			if field.Type == models.FieldTypeFile {
+				wantFiles[field.Field] = true
+				continue
+			}
+
+			value := resp.Answer
+			if mapped {
+				var err error
+				if value, err = convertAnswer(resp.Answer, field.Type); err != nil {
+					t.Fatalf("stored an applicant although %q = %#v is not a %s", resp.Question, resp.Answer, field.Type)
+				}
+			}
+			if _, builtin := applicantFieldTypes[field.Field]; builtin {
+				value, _ = value.(string)
+			}
+			want[field.Field] = value
+		}
+
+		for field, value := range want {
+			if got := applicantValue(got, field); !reflect.DeepEqual(got, value) {
+				t.Fatalf("%s = %#v, want %#v", field, got, value)
+			}
+		}
+		if len(got.Answers) > len(want) {
+			t.Fatalf("applicant has %d answers, only %d were submitted", len(got.Answers), len(want))
+		}
+		for _, file := range files {
+			if file.file == nil || !wantFiles[file.field.Field] {
+				t.Fatalf("file answer for %q was passed on without a submitted file", file.field.Field)
+			}
+		}
+		if len(files) < len(wantFiles) {
+			t.Fatalf("%d file answers passed on for %d file fields", len(files), len(wantFiles))
+		}
+	})
+}
+
+func TestDuplicateKind(t *testing.T) {
+	base := func() models.Applicant {
+		return models.Applicant{FirstName: "Ada", LastName: "Lovelace", Major: "Math", Year: "2nd"}
+	}
+	withAnswers := func(a models.Applicant, answers map[string]interface{}) models.Applicant {
+		a.Answers = answers
+		return a
+	}
+	withEmail := func(a models.Applicant, email string) models.Applicant {
+		a.Email = email
+		return a
+	}
+	essays := map[string]interface{}{"why": "Because", "clubs": "Chess", "gpa": "3.9"}
+	file := &models.FileInfo{FileID: "f", UniqueName: "1_t.pdf"}
+
+	tests := []struct {
+		name                string
+		existing, submitted models.Applicant
+		want                int
+	}{
+		{"same email", withEmail(base(), "ada@x.edu"), withEmail(models.Applicant{FirstName: "Adda"}, " ADA@x.edu"), resubmission},
+		{"different emails", withEmail(withAnswers(base(), essays), "ada@x.edu"), withEmail(withAnswers(base(), essays), "other@x.edu"), notDuplicate},
+		{"same name, major and year only", base(), base(), possibleDuplicate},
+		{"same name and answers", withAnswers(base(), essays), withAnswers(base(), essays), resubmission},
+		{"same name and answers, one email", withAnswers(base(), essays), withEmail(withAnswers(base(), essays), "ada@x.edu"), resubmission},
+		{"same name, different answers", withAnswers(base(), essays), withAnswers(base(), map[string]interface{}{"why": "Friends", "clubs": "Golf", "gpa": "3.1"}), possibleDuplicate},
+		{"files aren't compared", withAnswers(base(), map[string]interface{}{"transcript": file}), withAnswers(base(), map[string]interface{}{"transcript": file}), possibleDuplicate},
+		{"different name", base(), models.Applicant{FirstName: "Grace", LastName: "Hopper", Major: "Math", Year: "2nd"}, notDuplicate},
+		{"no name", models.Applicant{}, models.Applicant{}, notDuplicate},
+	}
+
+	for _, tt := range tests {
+		if got := duplicateKind(tt.existing, tt.submitted); got != tt.want {
+			t.Errorf("%s: duplicateKind = %d, want %d", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package controllers
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"backend/db"
+	"backend/models"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// how long a reviewer holds a served pair before it can be shown to someone else
+const leaseDuration = 10 * time.Minute
+
+var (
+	errLeaseNotFound = errors.New("lease not found")
+	errLeaseExpired  = errors.New("lease expired")
+	errLeaseMismatch = errors.New("lease does not cover these applicants")
+	errLeaseReviewer = errors.New("lease belongs to another reviewer")
+	errLeaseUsed     = errors.New("lease was already used for a vote")
+)
+
+type LeaseController struct {
+	collection *mongo.Collection
+}
+
+func NewLeaseController() *LeaseController {
+	return &LeaseController{
+		collection: db.GetCollection("leases"),
+	}
+}
+
+// Release hands a leased pair back without a vote, e.g. when the reviewer leaves the page
+func (lc *LeaseController) Release(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	leaseID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Lease ID", http.StatusBadRequest)
+		return
+	}
+
+	var lease models.Lease
+	if err := lc.collection.FindOne(ctx, bson.M{"_id": leaseID}).Decode(&lease); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Lease not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch lease", http.StatusInternalServerError)
+		log.Println("MongoDB Find lease error:", err)
+		return
+	}
+	if lease.ReviewerID != "" && lease.ReviewerID != reviewerID(r) {
+		http.Error(w, "Lease belongs to another reviewer", http.StatusForbidden)
+		return
+	}
+
+	if err := releaseLease(ctx, lease); err != nil {
+		http.Error(w, "Failed to release lease", http.StatusInternalServerError)
+		log.Println("Release lease error:", err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// lease helper functions
+
+func samePair(a1, b1, a2, b2 primitive.ObjectID) bool {
+	return (a1 == a2 && b1 == b2) || (a1 == b2 && b1 == a2)
+}
+
+func createLease(ctx context.Context, projectID, a, b primitive.ObjectID, reviewer string) (models.Lease, error) {
+	now := time.Now()
+	lease := models.Lease{
+		ID:         primitive.NewObjectID(),
+		ProjectID:  projectID,
+		ApplicantA: a,
+		ApplicantB: b,
+		ReviewerID: reviewer,
+		CreatedAt:  now,
+		ExpiresAt:  now.Add(leaseDuration),
+	}
+	_, err := db.GetCollection("leases").InsertOne(ctx, lease)
+	return lease, err
+}
+
+// checkLease loads the lease a vote was cast with and makes sure it is still held by the
+// voting reviewer for the pair being voted on
+func checkLease(ctx context.Context, leaseIDStr, reviewer string, a, b primitive.ObjectID) (models.Lease, error) {
+	var lease models.Lease
+
+	leaseID, err := primitive.ObjectIDFromHex(leaseIDStr)
+	if err != nil {
+		return lease, errLeaseNotFound
+	}
+	if err := db.GetCollection("leases").FindOne(ctx, bson.M{"_id": leaseID}).Decode(&lease); err != nil {
+		if err == mongo.ErrNoDocuments {
+			return lease, errLeaseNotFound
+		}
+		return lease, err
+	}
+
+	if !samePair(lease.ApplicantA, lease.ApplicantB, a, b) {
+		return lease, errLeaseMismatch
+	}
+	if lease.ReviewerID != "" && lease.ReviewerID != reviewer {
+		return lease, errLeaseReviewer
+	}
+	// the pair may already have been handed to someone else
+	if !lease.ExpiresAt.After(time.Now()) {
+		return lease, errLeaseExpired
+	}
+	return lease, nil
+}
+
+// releaseLease drops a lease that ended without a vote, making its pair available again
+func releaseLease(ctx context.Context, lease models.Lease) error {
+	if _, err := db.GetCollection("leases").DeleteOne(ctx, bson.M{"_id": lease.ID}); err != nil {
+		return err
+	}
+
+	if pool, ok := pairPools.Peek(lease.ProjectID); ok {
+		pool.Release(lease.ApplicantA, lease.ApplicantB)
+	}
+	return releaseSwissPairing(ctx, lease.ProjectID, lease.ApplicantA, lease.ApplicantB)
+}
+
+// activeLeases are the project's leases that haven't expired yet
+func activeLeases(ctx context.Context, projectID primitive.ObjectID) ([]models.Lease, error) {
+	cursor, err := db.GetCollection("leases").Find(ctx, bson.M{"project_id": projectID, "expires_at": bson.M{"$gt": time.Now()}})
+	if err != nil {
+		return nil, err
+	}
+
+	var leases []models.Lease
+	if err = cursor.All(ctx, &leases); err != nil {
+		return nil, err
+	}
+	return leases, nil
+}
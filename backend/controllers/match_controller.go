@@ -0,0 +1,437 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"backend/db"
+	"backend/elo"
+	"backend/middleware"
+	"backend/models"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+	defaultMatchPageSize = 50
+	maxMatchPageSize     = 200
+)
+
+type MatchController struct {
+	collection *mongo.Collection
+	applicants *mongo.Collection
+}
+
+func NewMatchController() *MatchController {
+	return &MatchController{
+		collection: db.GetCollection("matches"),
+		applicants: db.GetCollection("applicants"),
+	}
+}
+
+// match helper functions
+
+// reviewerID identifies who cast a vote: the Clerk user when auth is enabled,
+// otherwise whatever the frontend sent in the X-Reviewer-ID header
+func reviewerID(r *http.Request) string {
+	if userID, ok := middleware.GetUserID(r.Context()); ok {
+		return userID
+	}
+	return r.Header.Get("X-Reviewer-ID")
+}
+
+// outcomeScore is the score the first applicant of a match earned
+func outcomeScore(outcome string) float64 {
+	if outcome == models.OutcomeDraw {
+		return elo.Draw
+	}
+	return elo.Win
+}
+
+// outcomeCounters names the applicant counters each side of a match increments,
+// matches recorded before outcomes existed are wins
+func outcomeCounters(outcome string) (string, string) {
+	switch outcome {
+	case models.OutcomeDraw:
+		return "draws", "draws"
+	case models.OutcomeSkip:
+		return "", ""
+	default:
+		return "wins", "losses"
+	}
+}
+
+func parsePagination(r *http.Request) (int, int) {
+	page, err := strconv.Atoi(r.URL.Query().Get("page"))
+	if err != nil || page < 1 {
+		page = 1
+	}
+
+	limit, err := strconv.Atoi(r.URL.Query().Get("limit"))
+	if err != nil || limit < 1 {
+		limit = defaultMatchPageSize
+	}
+	if limit > maxMatchPageSize {
+		limit = maxMatchPageSize
+	}
+
+	return page, limit
+}
+
+func (mc *MatchController) GetByProject(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	page, limit := parsePagination(r)
+	filter := bson.M{"project_id": projectID}
+
+	total, err := mc.collection.CountDocuments(ctx, filter)
+	if err != nil {
+		http.Error(w, "Failed to count matches", http.StatusInternalServerError)
+		log.Println("MongoDB Count matches error:", err)
+		return
+	}
+
+	// newest votes first
+	opts := options.Find().
+		SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}}).
+		SetSkip(int64((page - 1) * limit)).
+		SetLimit(int64(limit))
+
+	cursor, err := mc.collection.Find(ctx, filter, opts)
+	if err != nil {
+		http.Error(w, "Failed to fetch matches", http.StatusInternalServerError)
+		log.Println("MongoDB Find matches error:", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	matches := []models.Match{}
+	if err = cursor.All(ctx, &matches); err != nil {
+		http.Error(w, "Error decoding matches", http.StatusInternalServerError)
+		log.Println("Cursor decode error:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"matches": matches,
+		"page":    page,
+		"limit":   limit,
+		"total":   total,
+	})
+}
+
+// ReplayProjectRatings resets every applicant in the project to its rating system's
+// initial rating and replays the project's recorded matches oldest first, rewriting
+// ratings, win/loss counts and the before/after ratings on each match in a single
+// transaction. Returns the number of applicants and matches that were replayed.
+func ReplayProjectRatings(ctx context.Context, projectID primitive.ObjectID) (int, int, error) {
+	var applicantCount, matchCount int
+	err := db.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		var err error
+		applicantCount, matchCount, err = replayProject(sc, projectID)
+		return err
+	})
+	if err != nil {
+		return 0, 0, err
+	}
+	pairPools.Invalidate(projectID)
+	return applicantCount, matchCount, nil
+}
+
+// replayProject does the work of ReplayProjectRatings inside the caller's transaction
+func replayProject(sc mongo.SessionContext, projectID primitive.ObjectID) (int, int, error) {
+	applicantsCollection := db.GetCollection("applicants")
+	matchesCollection := db.GetCollection("matches")
+	system := ratingSystemFor(sc, projectID)
+
+	cursor, err := applicantsCollection.Find(sc, bson.M{"project_id": projectID}, options.Find().SetProjection(bson.M{"_id": 1}))
+	if err != nil {
+		return 0, 0, err
+	}
+	var applicants []models.Applicant
+	if err = cursor.All(sc, &applicants); err != nil {
+		return 0, 0, err
+	}
+
+	ratings := make(map[primitive.ObjectID]elo.Rating, len(applicants))
+	counters := make(map[primitive.ObjectID]map[string]int, len(applicants))
+	for _, applicant := range applicants {
+		ratings[applicant.ID] = system.Initial()
+		counters[applicant.ID] = map[string]int{"wins": 0, "losses": 0, "draws": 0}
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}, {Key: "_id", Value: 1}})
+	cursor, err = matchesCollection.Find(sc, bson.M{"project_id": projectID}, opts)
+	if err != nil {
+		return 0, 0, err
+	}
+	var matches []models.Match
+	if err = cursor.All(sc, &matches); err != nil {
+		return 0, 0, err
+	}
+
+	matchWrites := make([]mongo.WriteModel, 0, len(matches))
+	for _, match := range matches {
+		winnerBefore, ok := ratings[match.WinnerID]
+		if !ok {
+			winnerBefore = system.Initial()
+		}
+		loserBefore, ok := ratings[match.LoserID]
+		if !ok {
+			loserBefore = system.Initial()
+		}
+
+		winnerAfter, loserAfter := winnerBefore, loserBefore
+		if match.Outcome != models.OutcomeSkip {
+			winnerAfter, loserAfter = system.Update(winnerBefore, loserBefore, outcomeScore(match.Outcome), match.Margin)
+			ratings[match.WinnerID] = winnerAfter
+			ratings[match.LoserID] = loserAfter
+
+			winnerCounter, loserCounter := outcomeCounters(match.Outcome)
+			if c, ok := counters[match.WinnerID]; ok {
+				c[winnerCounter]++
+			}
+			if c, ok := counters[match.LoserID]; ok {
+				c[loserCounter]++
+			}
+		}
+
+		matchWrites = append(matchWrites, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": match.ID}).
+			SetUpdate(bson.M{"$set": bson.M{
+				"winner_elo_before": winnerBefore.Elo(),
+				"loser_elo_before":  loserBefore.Elo(),
+				"winner_elo_after":  winnerAfter.Elo(),
+				"loser_elo_after":   loserAfter.Elo(),
+			}}))
+	}
+
+	applicantWrites := make([]mongo.WriteModel, 0, len(applicants))
+	for _, applicant := range applicants {
+		applicantFields := ratingFields(ratings[applicant.ID])
+		for counter, value := range counters[applicant.ID] {
+			applicantFields[counter] = value
+		}
+		applicantWrites = append(applicantWrites, mongo.NewUpdateOneModel().
+			SetFilter(bson.M{"_id": applicant.ID}).
+			SetUpdate(bson.M{"$set": applicantFields}))
+	}
+
+	if len(applicantWrites) > 0 {
+		if _, err := applicantsCollection.BulkWrite(sc, applicantWrites); err != nil {
+			return 0, 0, err
+		}
+	}
+	if len(matchWrites) > 0 {
+		if _, err := matchesCollection.BulkWrite(sc, matchWrites); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(applicants), len(matches), nil
+}
+
+func (mc *MatchController) Replay(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	// finalized rankings stay as they are
+	if !projectAllows(lookupProject(ctx, projectID), models.ProjectDraft, models.ProjectAcceptingApplications, models.ProjectReviewing) {
+		http.Error(w, "Ratings of a finalized project can't be replayed", http.StatusConflict)
+		return
+	}
+
+	applicantCount, matchCount, err := ReplayProjectRatings(ctx, projectID)
+	if err != nil {
+		http.Error(w, "Failed to replay matches", http.StatusInternalServerError)
+		log.Println("Replay project ratings error:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"applicants": applicantCount,
+		"matches":    matchCount,
+	})
+}
+
+// undoMatch deletes a recorded vote and restores both applicants. If neither applicant
+// has played since, their recorded pre-match ratings are put back directly; otherwise
+// later votes were computed from the ratings this one produced, so the project is replayed.
+// Matches only record Elo values, so projects on other rating systems are always replayed
+func (mc *MatchController) undoMatch(ctx context.Context, match models.Match) error {
+	ids := []primitive.ObjectID{match.WinnerID, match.LoserID}
+	system := ratingSystemFor(ctx, match.ProjectID)
+	defer pairPools.Invalidate(match.ProjectID)
+
+	err := db.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		later, err := mc.collection.CountDocuments(sc, bson.M{
+			"project_id": match.ProjectID,
+			"_id":        bson.M{"$ne": match.ID},
+			"created_at": bson.M{"$gte": match.CreatedAt},
+			"$or": []bson.M{
+				{"winner_id": bson.M{"$in": ids}},
+				{"loser_id": bson.M{"$in": ids}},
+			},
+		})
+		if err != nil {
+			return err
+		}
+
+		if _, err := mc.collection.DeleteOne(sc, bson.M{"_id": match.ID}); err != nil {
+			return err
+		}
+
+		// skips never changed ratings, progress or who has met whom, there is nothing to restore
+		if match.Outcome == models.OutcomeSkip {
+			return nil
+		}
+
+		// let the pair be shown again
+		if _, err := mc.applicants.UpdateOne(sc, bson.M{"_id": match.WinnerID}, bson.M{"$pull": bson.M{"matches_played": match.LoserID}}); err != nil {
+			return err
+		}
+		if _, err := mc.applicants.UpdateOne(sc, bson.M{"_id": match.LoserID}, bson.M{"$pull": bson.M{"matches_played": match.WinnerID}}); err != nil {
+			return err
+		}
+		if err := reopenSwissPairing(sc, match); err != nil {
+			return err
+		}
+
+		// votes from before the last history reset no longer count towards progress
+		if _, err := db.GetCollection("projects").UpdateOne(sc,
+			bson.M{"_id": match.ProjectID, "$or": []bson.M{
+				{"historyResetAt": bson.M{"$exists": false}},
+				{"historyResetAt": bson.M{"$lt": match.CreatedAt}},
+			}},
+			bson.M{"$inc": bson.M{"completedComparisons": -1}},
+		); err != nil {
+			return err
+		}
+
+		if later > 0 || system.Name() != elo.SystemElo {
+			_, _, err = replayProject(sc, match.ProjectID)
+			return err
+		}
+
+		winnerCounter, loserCounter := outcomeCounters(match.Outcome)
+		// Elo ratings are whole numbers, so the recorded ones are exact
+		updateWinner := bson.M{"$set": bson.M{"elo": match.WinnerEloBefore, "rating": float64(match.WinnerEloBefore)}, "$inc": bson.M{winnerCounter: -1}}
+		updateLoser := bson.M{"$set": bson.M{"elo": match.LoserEloBefore, "rating": float64(match.LoserEloBefore)}, "$inc": bson.M{loserCounter: -1}}
+		if _, err := mc.applicants.UpdateOne(sc, bson.M{"_id": match.WinnerID}, updateWinner); err != nil {
+			return err
+		}
+		if _, err := mc.applicants.UpdateOne(sc, bson.M{"_id": match.LoserID}, updateLoser); err != nil {
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// the pair can be reviewed again
+	setComparisonStatus(ctx, match.ProjectID, models.ComparisonsOpen)
+	return nil
+}
+
+func (mc *MatchController) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	matchID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Match ID", http.StatusBadRequest)
+		return
+	}
+
+	var match models.Match
+	if err := mc.collection.FindOne(ctx, bson.M{"_id": matchID}).Decode(&match); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Match not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch match", http.StatusInternalServerError)
+		return
+	}
+
+	if !projectAllows(lookupProject(ctx, match.ProjectID), models.ProjectReviewing) {
+		http.Error(w, "Votes can only be undone while the project is in review", http.StatusConflict)
+		return
+	}
+
+	if err := mc.undoMatch(ctx, match); err != nil {
+		http.Error(w, "Failed to undo match", http.StatusInternalServerError)
+		log.Println("Undo match error:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(match)
+}
+
+// UndoLast retracts the most recent vote the current reviewer cast in a project
+func (mc *MatchController) UndoLast(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	reviewer := reviewerID(r)
+	if reviewer == "" {
+		http.Error(w, "Reviewer ID required", http.StatusBadRequest)
+		return
+	}
+
+	var match models.Match
+	opts := options.FindOne().SetSort(bson.D{{Key: "created_at", Value: -1}, {Key: "_id", Value: -1}})
+	err = mc.collection.FindOne(ctx, bson.M{"project_id": projectID, "reviewer_id": reviewer}, opts).Decode(&match)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "No votes to undo", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch match", http.StatusInternalServerError)
+		return
+	}
+
+	if !projectAllows(lookupProject(ctx, match.ProjectID), models.ProjectReviewing) {
+		http.Error(w, "Votes can only be undone while the project is in review", http.StatusConflict)
+		return
+	}
+
+	if err := mc.undoMatch(ctx, match); err != nil {
+		http.Error(w, "Failed to undo match", http.StatusInternalServerError)
+		log.Println("Undo match error:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(match)
+}
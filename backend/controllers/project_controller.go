@@ -2,18 +2,27 @@ package controllers
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"encoding/json"
+	"io"
 	"log"
 	"math"
 	"net/http"
+	"strings"
 	"time"
 
 	"backend/db"
+	"backend/elo"
 	"backend/models"
+	"backend/pairing"
 
+	"github.com/go-chi/chi/v5"
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/gridfs"
+	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
 type ProjectController struct {
@@ -30,7 +39,13 @@ func (pc *ProjectController) GetAll(w http.ResponseWriter, r *http.Request) {
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	cursor, err := pc.collection.Find(ctx, bson.M{})
+	// archived projects are hidden unless asked for
+	filter := bson.M{"status": bson.M{"$ne": models.ProjectArchived}}
+	if r.URL.Query().Get("include_archived") == "true" {
+		filter = bson.M{}
+	}
+
+	cursor, err := pc.collection.Find(ctx, filter)
 	if err != nil {
 		http.Error(w, "Failed to fetch projects", http.StatusInternalServerError)
 		log.Println("MongoDB Find project error: ", err)
@@ -61,15 +76,66 @@ func (pc *ProjectController) Create(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	ratingSystem, ok := elo.GetSystem(project.RatingSystem)
+	if !ok {
+		http.Error(w, "Unknown rating system", http.StatusBadRequest)
+		return
+	}
+	project.RatingSystem = ratingSystem.Name()
+
+	if !pairing.ValidStrategy(project.PairingStrategy) {
+		http.Error(w, "Unknown pairing strategy", http.StatusBadRequest)
+		return
+	}
+	if project.PairingStrategy == "" {
+		project.PairingStrategy = pairing.StrategyClosest
+	}
+
+	if project.ReviewsPerPair < 0 {
+		http.Error(w, "Reviews per pair can't be negative", http.StatusBadRequest)
+		return
+	}
+	if project.ReviewsPerPair == 0 {
+		project.ReviewsPerPair = 1
+	}
+
+	formIDs, ok := normalizeFormIDs(project.FormIDs)
+	if !ok {
+		http.Error(w, "Form IDs can't be empty", http.StatusBadRequest)
+		return
+	}
+	project.FormIDs = formIDs
+
+	if err := validateFormSchema(project.FormSchema); err != nil {
+		http.Error(w, "Invalid form schema: "+err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// a project can start out taking applications straight away
+	if project.Status == "" {
+		project.Status = models.ProjectDraft
+	}
+	if project.Status != models.ProjectDraft && project.Status != models.ProjectAcceptingApplications {
+		http.Error(w, "New projects must start as draft or accepting_applications", http.StatusBadRequest)
+		return
+	}
+
 	project.ID = primitive.NewObjectID()
+	// progress is counted as applications arrive and votes are cast
+	project.TotalApplicants = 0
 	project.CompletedComparisons = 0
+	project.TotalComparisons = 0
+	project.Pass = 1
+	project.ComparisonStatus = models.ComparisonsOpen
 
 	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer cancel()
 
-	project.TotalComparisons = calculateSwissTotalComparisons(int(150)) // NEED APPLICATIONS IN MONGODB
-
 	_, err := pc.collection.InsertOne(ctx, project)
+	if mongo.IsDuplicateKeyError(err) {
+		http.Error(w, "A form ID is already registered to another project", http.StatusConflict)
+		return
+	}
 	if err != nil {
 		http.Error(w, "Failed to create project", http.StatusInternalServerError)
 		log.Println("mongoDB Insert project error:", err)
@@ -81,11 +147,601 @@ func (pc *ProjectController) Create(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(project)
 }
 
-func calculateSwissTotalComparisons(numApplicants int) int {
+func (pc *ProjectController) GetById(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	var project models.Project
+	if err := pc.collection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
+		log.Println("MongoDB Find project error:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// Update changes a project's settings and moves it along its lifecycle. Only the fields
+// present in the body are changed
+func (pc *ProjectController) Update(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		Name            *string `json:"name"`
+		Status          *string `json:"status"`
+		RatingSystem    *string `json:"ratingSystem"`
+		PairingStrategy *string `json:"pairingStrategy"`
+		ReviewsPerPair  *int    `json:"reviewsPerPair"`
+		// replace the registered form IDs and the form schema
+		FormIDs    *[]string           `json:"formIds"`
+		FormSchema *[]models.FormField `json:"formSchema"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	var project models.Project
+	if err := pc.collection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
+		log.Println("MongoDB Find project error:", err)
+		return
+	}
+	if project.Status == models.ProjectArchived {
+		http.Error(w, "Project is archived", http.StatusConflict)
+		return
+	}
+
+	update := bson.M{}
+	if request.Name != nil {
+		if *request.Name == "" {
+			http.Error(w, "Project name is required", http.StatusBadRequest)
+			return
+		}
+		update["name"] = *request.Name
+	}
+
+	if request.Status != nil && *request.Status != project.Status {
+		if !validTransition(project.Status, *request.Status) {
+			http.Error(w, "Can't move project from "+project.Status+" to "+*request.Status, http.StatusConflict)
+			return
+		}
+		update["status"] = *request.Status
+	}
+
+	if request.RatingSystem != nil {
+		system, ok := elo.GetSystem(*request.RatingSystem)
+		if !ok {
+			http.Error(w, "Unknown rating system", http.StatusBadRequest)
+			return
+		}
+		// ratings already computed would need a replay under the new system
+		if system.Name() != project.RatingSystem && !projectAllows(project, models.ProjectDraft, models.ProjectAcceptingApplications) {
+			http.Error(w, "Rating system can't change once reviewing has started", http.StatusConflict)
+			return
+		}
+		update["ratingSystem"] = system.Name()
+	}
+
+	if request.PairingStrategy != nil {
+		if *request.PairingStrategy == "" || !pairing.ValidStrategy(*request.PairingStrategy) {
+			http.Error(w, "Unknown pairing strategy", http.StatusBadRequest)
+			return
+		}
+		update["pairingStrategy"] = *request.PairingStrategy
+	}
+
+	if request.ReviewsPerPair != nil {
+		if *request.ReviewsPerPair < 1 {
+			http.Error(w, "Reviews per pair must be at least 1", http.StatusBadRequest)
+			return
+		}
+		update["reviewsPerPair"] = *request.ReviewsPerPair
+	}
+
+	if request.FormIDs != nil {
+		formIDs, ok := normalizeFormIDs(*request.FormIDs)
+		if !ok {
+			http.Error(w, "Form IDs can't be empty", http.StatusBadRequest)
+			return
+		}
+		update["formIds"] = formIDs
+	}
+
+	if request.FormSchema != nil {
+		if err := validateFormSchema(*request.FormSchema); err != nil {
+			http.Error(w, "Invalid form schema: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		update["formSchema"] = *request.FormSchema
+	}
+
+	if len(update) > 0 {
+		_, err := pc.collection.UpdateOne(ctx, bson.M{"_id": projectID}, bson.M{"$set": update})
+		if mongo.IsDuplicateKeyError(err) {
+			http.Error(w, "A form ID is already registered to another project", http.StatusConflict)
+			return
+		}
+		if err != nil {
+			http.Error(w, "Failed to update project", http.StatusInternalServerError)
+			log.Println("MongoDB Update project error:", err)
+			return
+		}
+
+		// pairing settings and totals depend on these
+		pairPools.Invalidate(projectID)
+		if err := refreshProjectTotals(ctx, projectID); err != nil {
+			log.Println("Refresh project totals error:", err)
+		}
+	}
+
+	if err := pc.collection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
+		log.Println("MongoDB Find project error:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// Archive retires a project from any state. Archived projects are read only
+func (pc *ProjectController) Archive(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	var project models.Project
+	err = pc.collection.FindOneAndUpdate(ctx,
+		bson.M{"_id": projectID},
+		bson.M{"$set": bson.M{"status": models.ProjectArchived}},
+		options.FindOneAndUpdate().SetReturnDocument(options.After),
+	).Decode(&project)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to archive project", http.StatusInternalServerError)
+		log.Println("MongoDB Update project error:", err)
+		return
+	}
+	pairPools.Invalidate(projectID)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// Delete removes a draft or archived project along with its applicants, their files
+// and everything recorded about the project's reviews
+func (pc *ProjectController) Delete(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	var project models.Project
+	if err := pc.collection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
+		log.Println("MongoDB Find project error:", err)
+		return
+	}
+	if project.Status != models.ProjectDraft && project.Status != models.ProjectArchived {
+		http.Error(w, "Archive the project before deleting it", http.StatusConflict)
+		return
+	}
+
+	var applicants []models.Applicant
+	err = db.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		cursor, err := db.GetCollection("applicants").Find(sc, bson.M{"project_id": projectID},
+			options.Find().SetProjection(bson.M{"image": 1, "coverLetter": 1, "resume": 1, "answers": 1}))
+		if err != nil {
+			return err
+		}
+		if err = cursor.All(sc, &applicants); err != nil {
+			return err
+		}
+
+		for _, name := range []string{"applicants", "matches", "rounds", "leases", "passes"} {
+			if _, err := db.GetCollection(name).DeleteMany(sc, bson.M{"project_id": projectID}); err != nil {
+				return err
+			}
+		}
+		_, err = pc.collection.DeleteOne(sc, bson.M{"_id": projectID})
+		return err
+	})
+	if err != nil {
+		http.Error(w, "Failed to delete project", http.StatusInternalServerError)
+		log.Println("Delete project error:", err)
+		return
+	}
+	pairPools.Invalidate(projectID)
+
+	// files live outside the transaction, a leftover file is only wasted space
+	bucket, err := gridfs.NewBucket(db.Client.Database("akpsi-ucsb"))
+	if err != nil {
+		log.Println("Error creating GridFS bucket:", err)
+	} else {
+		for _, applicant := range applicants {
+			for _, file := range applicantFiles(applicant) {
+				deleteFile(bucket, file)
+			}
+		}
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+type projectProgress struct {
+	ProjectID            primitive.ObjectID `json:"projectId"`
+	TotalApplicants      int                `json:"totalApplicants"`
+	CompletedComparisons int                `json:"completedComparisons"`
+	TotalComparisons     int                `json:"totalComparisons"`
+	PercentComplete      float64            `json:"percentComplete"`
+	RemainingVotes       int                `json:"remainingVotes"`
+	ComparisonStatus     string             `json:"comparisonStatus"`
+	Pass                 int                `json:"pass"`
+}
+
+func (pc *ProjectController) GetProgress(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	var project models.Project
+	if err := pc.collection.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to fetch project", http.StatusInternalServerError)
+		log.Println("MongoDB Find project error:", err)
+		return
+	}
+
+	progress := projectProgress{
+		ProjectID:            project.ID,
+		TotalApplicants:      project.TotalApplicants,
+		CompletedComparisons: project.CompletedComparisons,
+		TotalComparisons:     project.TotalComparisons,
+		RemainingVotes:       project.TotalComparisons - project.CompletedComparisons,
+		ComparisonStatus:     project.ComparisonStatus,
+		Pass:                 project.Pass,
+	}
+	if progress.RemainingVotes < 0 || project.ComparisonStatus == models.ComparisonsExhausted {
+		progress.RemainingVotes = 0
+	}
+	if project.TotalComparisons > 0 {
+		progress.PercentComplete = math.Min(100, 100*float64(project.CompletedComparisons)/float64(project.TotalComparisons))
+	}
+	if project.ComparisonStatus == models.ComparisonsExhausted {
+		progress.PercentComplete = 100
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(progress)
+}
+
+// ResetHistory clears a project's pairing history so its pairs can be reviewed again.
+// With newPass set the reset is recorded as a new pass, and votes cast afterwards are
+// tagged with it
+func (pc *ProjectController) ResetHistory(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	var request struct {
+		NewPass bool   `json:"newPass"`
+		Note    string `json:"note"`
+	}
+	// the body is optional, a plain reset doesn't need one
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil && err != io.EOF {
+		http.Error(w, "Invalid JSON input", http.StatusBadRequest)
+		return
+	}
+
+	if !projectAllows(lookupProject(ctx, projectID), models.ProjectReviewing) {
+		http.Error(w, "Project is not open for review", http.StatusConflict)
+		return
+	}
+
+	project, err := ResetProjectHistory(ctx, projectID, request.NewPass, reviewerID(r), request.Note)
+	if err != nil {
+		if err == mongo.ErrNoDocuments {
+			http.Error(w, "Project not found", http.StatusNotFound)
+			return
+		}
+		http.Error(w, "Failed to reset history", http.StatusInternalServerError)
+		log.Println("Reset history error:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(project)
+}
+
+// project helper functions
+
+// RotateWebhookSecret issues a new secret for signing the project's form submissions and
+// returns it. Older secrets keep working for grace, a grace of 0 revokes them straight
+// away. Secrets are never handed out over the API, run
+// scripts/rotateWebhookSecretScript to get one
+func RotateWebhookSecret(ctx context.Context, projectID primitive.ObjectID, grace time.Duration) (string, error) {
+	projects := db.GetCollection("projects")
+
+	var project models.Project
+	if err := projects.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		return "", err
+	}
+
+	secret, err := newWebhookSecret()
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	graceEnd := now.Add(grace)
+	secrets := []models.WebhookSecret{{Secret: secret, CreatedAt: now}}
+	for _, old := range activeWebhookSecrets(project, now) {
+		if grace <= 0 {
+			break
+		}
+		if old.ExpiresAt == nil || old.ExpiresAt.After(graceEnd) {
+			old.ExpiresAt = &graceEnd
+		}
+		secrets = append(secrets, old)
+	}
+
+	_, err = projects.UpdateOne(ctx, bson.M{"_id": projectID}, bson.M{"$set": bson.M{"webhookSecrets": secrets}})
+	return secret, err
+}
+
+// ResetProjectHistory forgets which pairs of the project have been reviewed, closing any
+// open Swiss round, without touching recorded votes or ratings. With newPass it also
+// starts and records the project's next pass. Returns the updated project
+func ResetProjectHistory(ctx context.Context, projectID primitive.ObjectID, newPass bool, startedBy, note string) (models.Project, error) {
+	projects := db.GetCollection("projects")
+
+	var project models.Project
+	err := db.WithTransaction(ctx, func(sc mongo.SessionContext) error {
+		if err := projects.FindOne(sc, bson.M{"_id": projectID}).Decode(&project); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if _, err := db.GetCollection("applicants").UpdateMany(sc,
+			bson.M{"project_id": projectID},
+			bson.M{"$set": bson.M{"matches_played": []primitive.ObjectID{}}},
+		); err != nil {
+			return err
+		}
+		if _, err := db.GetCollection("rounds").UpdateMany(sc,
+			bson.M{"project_id": projectID, "completed_at": bson.M{"$exists": false}},
+			bson.M{"$set": bson.M{"completed_at": now}},
+		); err != nil {
+			return err
+		}
+
+		// progress starts over along with the history
+		project.HistoryResetAt = &now
+		project.ComparisonStatus = models.ComparisonsOpen
+		project.CompletedComparisons = 0
+		if newPass {
+			project.Pass++
+			pass := models.Pass{
+				ID:        primitive.NewObjectID(),
+				ProjectID: projectID,
+				Number:    project.Pass,
+				StartedBy: startedBy,
+				Note:      note,
+				StartedAt: now,
+			}
+			if _, err := db.GetCollection("passes").InsertOne(sc, pass); err != nil {
+				return err
+			}
+		}
+
+		_, err := projects.UpdateOne(sc, bson.M{"_id": projectID}, bson.M{"$set": bson.M{
+			"historyResetAt":       project.HistoryResetAt,
+			"comparisonStatus":     project.ComparisonStatus,
+			"completedComparisons": project.CompletedComparisons,
+			"pass":                 project.Pass,
+		}})
+		return err
+	})
+	if err != nil {
+		return models.Project{}, err
+	}
+
+	pairPools.Invalidate(projectID)
+	log.Printf("Reset match history for project %s, pass %d", projectID.Hex(), project.Pass)
+	return project, nil
+}
+
+// setComparisonStatus records whether the project still has pairs left to review
+func setComparisonStatus(ctx context.Context, projectID primitive.ObjectID, status string) {
+	_, err := db.GetCollection("projects").UpdateOne(ctx,
+		bson.M{"_id": projectID, "comparisonStatus": bson.M{"$ne": status}},
+		bson.M{"$set": bson.M{"comparisonStatus": status}},
+	)
+	if err != nil {
+		log.Println("Update comparison status error:", err)
+	}
+}
+
+// normalizeFormIDs trims and dedupes form IDs, rejecting blank ones
+func normalizeFormIDs(formIDs []string) ([]string, bool) {
+	normalized := make([]string, 0, len(formIDs))
+	seen := make(map[string]bool, len(formIDs))
+	for _, formID := range formIDs {
+		formID = strings.TrimSpace(formID)
+		if formID == "" {
+			return nil, false
+		}
+		if !seen[formID] {
+			seen[formID] = true
+			normalized = append(normalized, formID)
+		}
+	}
+	return normalized, true
+}
+
+// lookupProject loads a project, or an empty one if it can't be found, for endpoints
+// that have always tolerated a missing project
+func lookupProject(ctx context.Context, projectID primitive.ObjectID) models.Project {
+	var project models.Project
+	_ = db.GetCollection("projects").FindOne(ctx, bson.M{"_id": projectID}).Decode(&project)
+	return project
+}
+
+// projectAllows reports whether a project's lifecycle state is one of statuses. Projects
+// created before lifecycle states existed have no status and allow everything
+func projectAllows(project models.Project, statuses ...string) bool {
+	if project.Status == "" {
+		return true
+	}
+	for _, status := range statuses {
+		if project.Status == status {
+			return true
+		}
+	}
+	return false
+}
+
+var projectLifecycle = []string{
+	models.ProjectDraft,
+	models.ProjectAcceptingApplications,
+	models.ProjectReviewing,
+	models.ProjectFinalized,
+	models.ProjectArchived,
+}
+
+// validTransition allows moving one step forward through the lifecycle, or straight
+// to archived from anywhere
+func validTransition(from, to string) bool {
+	if to == models.ProjectArchived {
+		return true
+	}
+
+	fromIndex, toIndex := -1, -1
+	for i, status := range projectLifecycle {
+		if status == from {
+			fromIndex = i
+		}
+		if status == to {
+			toIndex = i
+		}
+	}
+	if toIndex < 0 || from == models.ProjectArchived {
+		return false
+	}
+	// projects from before lifecycle states can be put in any state
+	return fromIndex < 0 || toIndex == fromIndex+1
+}
+
+func deleteFile(bucket *gridfs.Bucket, fileInfo *models.FileInfo) {
+	if fileInfo == nil {
+		return
+	}
+	fileID, err := primitive.ObjectIDFromHex(fileInfo.FileID)
+	if err != nil {
+		return
+	}
+	if err := bucket.Delete(fileID); err != nil && err != gridfs.ErrFileNotFound {
+		log.Println("GridFS delete error:", err)
+	}
+}
+
+// refreshProjectTotals recounts the project's applicants and the number of votes needed
+// to get through them, call it whenever applicants are added or removed
+func refreshProjectTotals(ctx context.Context, projectID primitive.ObjectID) error {
+	projects := db.GetCollection("projects")
+
+	var project models.Project
+	if err := projects.FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		return err
+	}
+
+	count, err := db.GetCollection("applicants").CountDocuments(ctx, bson.M{"project_id": projectID})
+	if err != nil {
+		return err
+	}
+
+	_, err = projects.UpdateOne(ctx, bson.M{"_id": projectID}, bson.M{"$set": bson.M{
+		"totalApplicants":  int(count),
+		"totalComparisons": totalComparisons(project, int(count)),
+	}})
+	return err
+}
+
+// totalComparisons is how many votes it takes to get through a project with n
+// applicants: every round of a Swiss tournament, or otherwise every pair judged by
+// as many reviewers as the project asks for
+func totalComparisons(project models.Project, numApplicants int) int {
 	if numApplicants < 2 {
 		return 0
 	}
 
-	rounds := int(math.Ceil(math.Log2(float64(numApplicants)))) + 2
-	return rounds * (numApplicants / 2)
-}
\ No newline at end of file
+	if project.PairingStrategy == pairing.StrategySwiss {
+		return pairing.SwissRounds(numApplicants) * (numApplicants / 2)
+	}
+
+	reviews := project.ReviewsPerPair
+	if reviews < 1 {
+		reviews = 1
+	}
+	return numApplicants * (numApplicants - 1) / 2 * reviews
+}
+
+func newWebhookSecret() (string, error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(buf), nil
+}
@@ -0,0 +1,383 @@
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"backend/db"
+	"backend/models"
+	"backend/pairing"
+
+	"github.com/go-chi/chi/v5"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var (
+	errSwissFinished       = errors.New("all swiss rounds are complete")
+	errNotEnoughApplicants = errors.New("not enough applicants for comparison")
+	errRoundBusy           = errors.New("every open pairing is leased to a reviewer")
+)
+
+type RoundController struct {
+	collection *mongo.Collection
+}
+
+func NewRoundController() *RoundController {
+	return &RoundController{
+		collection: db.GetCollection("rounds"),
+	}
+}
+
+func (rc *RoundController) GetByProject(w http.ResponseWriter, r *http.Request) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	projectID, err := primitive.ObjectIDFromHex(chi.URLParam(r, "id"))
+	if err != nil {
+		http.Error(w, "Invalid Project ID", http.StatusBadRequest)
+		return
+	}
+
+	opts := options.Find().SetSort(bson.D{{Key: "number", Value: 1}})
+	cursor, err := rc.collection.Find(ctx, bson.M{"project_id": projectID}, opts)
+	if err != nil {
+		http.Error(w, "Failed to fetch rounds", http.StatusInternalServerError)
+		log.Println("MongoDB Find rounds error:", err)
+		return
+	}
+	defer cursor.Close(ctx)
+
+	rounds := []models.Round{}
+	if err = cursor.All(ctx, &rounds); err != nil {
+		http.Error(w, "Error decoding rounds", http.StatusInternalServerError)
+		log.Println("Cursor decode error:", err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(rounds)
+}
+
+// swiss round helper functions
+
+// nextSwissPair hands out a pairing from the project's current Swiss round, starting the
+// next round once every pairing of the current one has a vote. Pairings nobody has been
+// shown go first, then the ones that were skipped or released, then the ones whose lease
+// ran out without a vote
+func nextSwissPair(ctx context.Context, projectID primitive.ObjectID) (primitive.ObjectID, primitive.ObjectID, error) {
+	rounds := db.GetCollection("rounds")
+
+	// another reviewer can take the chosen pairing first, so retry a few times
+	for attempt := 0; attempt < 5; attempt++ {
+		round, err := currentSwissRound(ctx, projectID)
+		if err != nil {
+			return primitive.NilObjectID, primitive.NilObjectID, err
+		}
+
+		index, leased := nextSwissPairing(round.Pairings, time.Now())
+		if index < 0 && leased {
+			return primitive.NilObjectID, primitive.NilObjectID, errRoundBusy
+		}
+		if index < 0 {
+			if err := closeSwissRound(ctx, round.ID); err != nil {
+				return primitive.NilObjectID, primitive.NilObjectID, err
+			}
+			continue
+		}
+
+		chosen := round.Pairings[index]
+		field := fmt.Sprintf("pairings.%d", index)
+		result, err := rounds.UpdateOne(ctx,
+			bson.M{"_id": round.ID, field + ".status": chosen.Status, field + ".served_at": chosen.ServedAt},
+			bson.M{"$set": bson.M{field + ".status": models.PairingServed, field + ".served_at": time.Now()}},
+		)
+		if err != nil {
+			return primitive.NilObjectID, primitive.NilObjectID, err
+		}
+		if result.ModifiedCount == 1 {
+			return chosen.ApplicantA, chosen.ApplicantB, nil
+		}
+	}
+
+	return primitive.NilObjectID, primitive.NilObjectID, errors.New("swiss round changed too often while choosing a pairing")
+}
+
+// currentSwissRound returns the project's open round, generating the next one if the
+// last round is complete
+func currentSwissRound(ctx context.Context, projectID primitive.ObjectID) (models.Round, error) {
+	rounds := db.GetCollection("rounds")
+
+	var last models.Round
+	opts := options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}})
+	err := rounds.FindOne(ctx, bson.M{"project_id": projectID}, opts).Decode(&last)
+	if err != nil && err != mongo.ErrNoDocuments {
+		return models.Round{}, err
+	}
+	if err == nil && last.CompletedAt == nil {
+		return last, nil
+	}
+
+	var project models.Project
+	if err := db.GetCollection("projects").FindOne(ctx, bson.M{"_id": projectID}).Decode(&project); err != nil {
+		return models.Round{}, err
+	}
+	// round numbers keep counting across resets, each reset starts a full tournament
+	// over. The reset closes the open round, so it counts as played before it but not after
+	since := bson.M{"project_id": projectID}
+	if project.HistoryResetAt != nil {
+		since["created_at"] = bson.M{"$gt": *project.HistoryResetAt}
+	}
+	played, err := rounds.CountDocuments(ctx, since)
+	if err != nil {
+		return models.Round{}, err
+	}
+
+	projection := options.Find().SetProjection(bson.M{"_id": 1, "elo": 1, "wins": 1, "draws": 1, "matches_played": 1})
+	cursor, err := db.GetCollection("applicants").Find(ctx, bson.M{"project_id": projectID}, projection)
+	if err != nil {
+		return models.Round{}, err
+	}
+	var applicants []models.Applicant
+	if err = cursor.All(ctx, &applicants); err != nil {
+		return models.Round{}, err
+	}
+
+	if len(applicants) < 2 {
+		return models.Round{}, errNotEnoughApplicants
+	}
+	if int(played) >= pairing.SwissRounds(len(applicants)) {
+		return models.Round{}, errSwissFinished
+	}
+
+	// a bye scores like a win, as in any Swiss tournament
+	byes := make(map[primitive.ObjectID]int)
+	since["bye"] = bson.M{"$exists": true}
+	cursor, err = rounds.Find(ctx, since)
+	if err != nil {
+		return models.Round{}, err
+	}
+	var previous []models.Round
+	if err = cursor.All(ctx, &previous); err != nil {
+		return models.Round{}, err
+	}
+	for _, round := range previous {
+		byes[*round.Bye]++
+	}
+
+	players := make([]pairing.SwissPlayer, 0, len(applicants))
+	met := make(map[primitive.ObjectID][]primitive.ObjectID, len(applicants))
+	for _, applicant := range applicants {
+		players = append(players, pairing.SwissPlayer{
+			ID:     applicant.ID,
+			Score:  float64(applicant.Wins) + float64(applicant.Draws)/2 + float64(byes[applicant.ID]),
+			Elo:    applicant.Elo,
+			HadBye: byes[applicant.ID] > 0,
+		})
+		met[applicant.ID] = applicant.MatchesPlayed
+	}
+
+	pairs, bye := pairing.PairSwissRound(players, met)
+
+	round := models.Round{
+		ID:        primitive.NewObjectID(),
+		ProjectID: projectID,
+		Number:    last.Number + 1,
+		Pass:      project.Pass,
+		Pairings:  make([]models.RoundPairing, 0, len(pairs)),
+		CreatedAt: time.Now(),
+	}
+	for _, pair := range pairs {
+		round.Pairings = append(round.Pairings, models.RoundPairing{
+			ApplicantA: pair[0],
+			ApplicantB: pair[1],
+			Status:     models.PairingPending,
+		})
+	}
+	if !bye.IsZero() {
+		round.Bye = &bye
+	}
+
+	if _, err := rounds.InsertOne(ctx, round); err != nil {
+		// another request started this round first, use theirs
+		if mongo.IsDuplicateKeyError(err) {
+			err = rounds.FindOne(ctx, bson.M{"project_id": projectID, "number": round.Number}).Decode(&round)
+		}
+		if err != nil {
+			return models.Round{}, err
+		}
+	}
+
+	log.Printf("Started Swiss round %d for project %s", round.Number, projectID.Hex())
+	return round, nil
+}
+
+// nextSwissPairing picks the pairing to serve next, -1 if there is none. Pending pairings
+// come first, the ones never shown in round order and then the ones given back longest
+// ago, so a reviewer who skips a pair isn't handed it straight back. Otherwise the
+// pairing whose lease expired longest ago is served again. leased reports whether any
+// pairing is still held by a reviewer
+func nextSwissPairing(pairings []models.RoundPairing, now time.Time) (index int, leased bool) {
+	index = -1
+	for i, p := range pairings {
+		if p.Status != models.PairingPending {
+			continue
+		}
+		if p.ReleasedAt == nil {
+			return i, false
+		}
+		if index < 0 || p.ReleasedAt.Before(*pairings[index].ReleasedAt) {
+			index = i
+		}
+	}
+	if index >= 0 {
+		return index, false
+	}
+
+	expired := now.Add(-leaseDuration)
+	for i, p := range pairings {
+		if p.Status != models.PairingServed {
+			continue
+		}
+		if p.ServedAt != nil && p.ServedAt.After(expired) {
+			leased = true
+			continue
+		}
+		if index < 0 || p.ServedAt.Before(*pairings[index].ServedAt) {
+			index = i
+		}
+	}
+	return index, leased
+}
+
+func closeSwissRound(ctx context.Context, roundID primitive.ObjectID) error {
+	_, err := db.GetCollection("rounds").UpdateOne(ctx,
+		bson.M{"_id": roundID, "completed_at": bson.M{"$exists": false}},
+		bson.M{"$set": bson.M{"completed_at": time.Now()}},
+	)
+	return err
+}
+
+// completeSwissPairing marks the open round's pairing of a and b as voted on. Votes on
+// pairs outside the current round, or on projects that don't run Swiss rounds, are ignored
+func completeSwissPairing(ctx context.Context, projectID, a, b, matchID primitive.ObjectID) error {
+	rounds := db.GetCollection("rounds")
+
+	var round models.Round
+	err := rounds.FindOne(ctx, bson.M{"project_id": projectID, "completed_at": bson.M{"$exists": false}}).Decode(&round)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	index := -1
+	remaining := 0
+	for i, p := range round.Pairings {
+		if p.Status == models.PairingCompleted {
+			continue
+		}
+		if samePair(p.ApplicantA, p.ApplicantB, a, b) {
+			index = i
+		} else {
+			remaining++
+		}
+	}
+	if index < 0 {
+		return nil
+	}
+
+	field := fmt.Sprintf("pairings.%d", index)
+	result, err := rounds.UpdateOne(ctx,
+		bson.M{"_id": round.ID, field + ".status": bson.M{"$ne": models.PairingCompleted}},
+		bson.M{"$set": bson.M{field + ".status": models.PairingCompleted, field + ".match_id": matchID}},
+	)
+	if err != nil {
+		return err
+	}
+	if result.ModifiedCount == 0 {
+		return nil
+	}
+
+	if remaining == 0 {
+		return closeSwissRound(ctx, round.ID)
+	}
+	return nil
+}
+
+// releaseSwissPairing puts the open round's served pairing of a and b at the back of the
+// line after it was skipped or its lease was given up
+func releaseSwissPairing(ctx context.Context, projectID, a, b primitive.ObjectID) error {
+	rounds := db.GetCollection("rounds")
+
+	var round models.Round
+	err := rounds.FindOne(ctx, bson.M{"project_id": projectID, "completed_at": bson.M{"$exists": false}}).Decode(&round)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, p := range round.Pairings {
+		if p.Status != models.PairingServed || !samePair(p.ApplicantA, p.ApplicantB, a, b) {
+			continue
+		}
+		field := fmt.Sprintf("pairings.%d", i)
+		_, err := rounds.UpdateOne(ctx,
+			bson.M{"_id": round.ID, field + ".status": models.PairingServed},
+			bson.M{
+				"$set":   bson.M{field + ".status": models.PairingPending, field + ".released_at": time.Now()},
+				"$unset": bson.M{field + ".served_at": ""},
+			},
+		)
+		return err
+	}
+	return nil
+}
+
+// reopenSwissPairing puts the pairing a retracted vote completed back in line, reopening
+// its round if the vote had closed it. Rounds already followed by another, or closed by a
+// history reset, are left alone: the tournament has moved on from them
+func reopenSwissPairing(ctx context.Context, match models.Match) error {
+	rounds := db.GetCollection("rounds")
+
+	project := lookupProject(ctx, match.ProjectID)
+	if project.HistoryResetAt != nil && match.CreatedAt.Before(*project.HistoryResetAt) {
+		return nil
+	}
+
+	var round models.Round
+	opts := options.FindOne().SetSort(bson.D{{Key: "number", Value: -1}})
+	err := rounds.FindOne(ctx, bson.M{"project_id": match.ProjectID}, opts).Decode(&round)
+	if err == mongo.ErrNoDocuments {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	for i, p := range round.Pairings {
+		if p.MatchID == nil || *p.MatchID != match.ID {
+			continue
+		}
+		field := fmt.Sprintf("pairings.%d", i)
+		_, err := rounds.UpdateOne(ctx,
+			bson.M{"_id": round.ID},
+			bson.M{
+				"$set":   bson.M{field + ".status": models.PairingPending},
+				"$unset": bson.M{field + ".match_id": "", field + ".served_at": "", field + ".released_at": "", "completed_at": ""},
+			},
+		)
+		return err
+	}
+	return nil
+}
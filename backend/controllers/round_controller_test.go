@@ -0,0 +1,47 @@
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	"backend/models"
+)
+
+func TestNextSwissPairing(t *testing.T) {
+	now := time.Now()
+	ago := func(d time.Duration) *time.Time {
+		at := now.Add(-d)
+		return &at
+	}
+	pending := models.RoundPairing{Status: models.PairingPending}
+	released := func(d time.Duration) models.RoundPairing {
+		return models.RoundPairing{Status: models.PairingPending, ReleasedAt: ago(d)}
+	}
+	served := func(d time.Duration) models.RoundPairing {
+		return models.RoundPairing{Status: models.PairingServed, ServedAt: ago(d)}
+	}
+	completed := models.RoundPairing{Status: models.PairingCompleted}
+
+	tests := []struct {
+		name       string
+		pairings   []models.RoundPairing
+		wantIndex  int
+		wantLeased bool
+	}{
+		{"first pending", []models.RoundPairing{completed, pending, pending}, 1, false},
+		{"skipped pairing goes after unshown ones", []models.RoundPairing{released(time.Second), pending}, 1, false},
+		{"oldest skip first", []models.RoundPairing{released(time.Second), released(time.Minute), completed}, 1, false},
+		{"only a skipped pairing left", []models.RoundPairing{completed, released(time.Second)}, 1, false},
+		{"expired lease served again", []models.RoundPairing{served(time.Minute), served(leaseDuration + time.Minute), completed}, 1, true},
+		{"longest expired first", []models.RoundPairing{served(leaseDuration + time.Minute), served(leaseDuration + time.Hour)}, 1, false},
+		{"everything leased", []models.RoundPairing{served(time.Minute), completed}, -1, true},
+		{"round complete", []models.RoundPairing{completed, completed}, -1, false},
+	}
+
+	for _, tt := range tests {
+		index, leased := nextSwissPairing(tt.pairings, now)
+		if index != tt.wantIndex || leased != tt.wantLeased {
+			t.Errorf("%s: got %d, %v, want %d, %v", tt.name, index, leased, tt.wantIndex, tt.wantLeased)
+		}
+	}
+}
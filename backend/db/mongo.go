@@ -2,9 +2,11 @@ package db
 
 import (
 	"context"
+	"errors"
 	"log"
 	"time"
 
+	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
@@ -29,8 +31,76 @@ func ConnectMongoDB(uri string) {
 
 	log.Println("Connected to MongoDB")
 	Client = client
+
+	createIndexes()
+}
+
+// indexes the endpoints rely on for uniqueness and cleanup, creating one that already
+// exists is a no-op
+func createIndexes() {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	indexes := map[string][]mongo.IndexModel{
+		"rounds": {
+			{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "number", Value: 1}}, Options: options.Index().SetUnique(true)},
+		},
+		// a form can only feed one project
+		"projects": {
+			{
+				Keys: bson.D{{Key: "formIds", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"formIds": bson.M{"$type": "string"}}),
+			},
+		},
+		// idempotency keys are each reviewer's own, within a project
+		"matches": {
+			{
+				Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "reviewer_id", Value: 1}, {Key: "idempotency_key", Value: 1}},
+				Options: options.Index().SetUnique(true).
+					SetPartialFilterExpression(bson.M{"idempotency_key": bson.M{"$type": "string"}}),
+			},
+		},
+		// expired leases are kept for an hour so late votes get a clear answer
+		"leases": {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(3600)},
+			{Keys: bson.D{{Key: "project_id", Value: 1}, {Key: "expires_at", Value: 1}}},
+		},
+		// signatures of accepted form submissions, kept until their timestamp would be rejected anyway
+		"webhook_deliveries": {
+			{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+		},
+	}
+
+	// the key used to be unique across all matches, which the index above doesn't replace
+	if _, err := GetCollection("matches").Indexes().DropOne(ctx, "idempotency_key_1"); err != nil {
+		var commandErr mongo.CommandError
+		if !errors.As(err, &commandErr) || commandErr.Name != "IndexNotFound" {
+			log.Fatalf("Failed to drop matches idempotency_key index: %v", err)
+		}
+	}
+
+	for collectionName, models := range indexes {
+		if _, err := GetCollection(collectionName).Indexes().CreateMany(ctx, models); err != nil {
+			log.Fatalf("Failed to create %s indexes: %v", collectionName, err)
+		}
+	}
 }
 
 func GetCollection(collectionName string) *mongo.Collection {
 	return Client.Database("akpsi-ucsb").Collection(collectionName)
-}
\ No newline at end of file
+}
+
+// runs fn inside a multi-document transaction, committing only if fn returns nil
+func WithTransaction(ctx context.Context, fn func(sc mongo.SessionContext) error) error {
+	session, err := Client.StartSession()
+	if err != nil {
+		return err
+	}
+	defer session.EndSession(ctx)
+
+	_, err = session.WithTransaction(ctx, func(sc mongo.SessionContext) (interface{}, error) {
+		return nil, fn(sc)
+	})
+	return err
+}
@@ -0,0 +1,176 @@
+package elo
+
+import (
+	"errors"
+	"math"
+	"sort"
+)
+
+// Bradley-Terry fits a strength p_i to every applicant so that
+// P(i beats j) = p_i / (p_i + p_j), using all comparisons at once so the result
+// doesn't depend on the order votes were cast in
+
+const (
+	// BTPriorGames is how many virtual games, half won and half lost, each applicant
+	// plays against a reference applicant of strength 1. It keeps strengths finite for
+	// applicants who have won or lost every comparison
+	BTPriorGames = 2.0
+
+	btMaxIterations = 10000
+	btTolerance     = 1e-9
+)
+
+// Comparison is one recorded vote between applicants at the given indices. A draw
+// counts as half a win for each side, and Margin weights the vote like
+// MarginMultiplier does for online updates
+type Comparison struct {
+	Winner int
+	Loser  int
+	Draw   bool
+	Margin int
+}
+
+type opponent struct {
+	index int
+	games float64
+}
+
+type Strength struct {
+	// Strength is the fitted p_i, relative to the reference applicant's 1
+	Strength float64
+	// Rating is the strength on the Elo scale, centred on InitialElo
+	Rating float64
+	// StdError is the standard error of Rating
+	StdError float64
+}
+
+// BradleyTerry fits strengths for n applicants with the MM algorithm (Hunter 2004)
+// and derives standard errors from the inverse of the observed information
+func BradleyTerry(n int, comparisons []Comparison) ([]Strength, error) {
+	if n == 0 {
+		return []Strength{}, nil
+	}
+
+	wins := make([]float64, n)
+	counts := make([]map[int]float64, n)
+	for i := range counts {
+		counts[i] = make(map[int]float64)
+	}
+	for _, c := range comparisons {
+		if c.Winner < 0 || c.Winner >= n || c.Loser < 0 || c.Loser >= n || c.Winner == c.Loser {
+			return nil, errors.New("comparison references an unknown applicant")
+		}
+		weight := MarginMultiplier(c.Margin)
+		if c.Draw {
+			wins[c.Winner] += weight / 2
+			wins[c.Loser] += weight / 2
+		} else {
+			wins[c.Winner] += weight
+		}
+		counts[c.Winner][c.Loser] += weight
+		counts[c.Loser][c.Winner] += weight
+	}
+
+	// sorted so floating point sums come out the same however the votes were ordered
+	games := make([][]opponent, n)
+	for i, byOpponent := range counts {
+		for j, count := range byOpponent {
+			games[i] = append(games[i], opponent{index: j, games: count})
+		}
+		sort.Slice(games[i], func(a, b int) bool { return games[i][a].index < games[i][b].index })
+	}
+
+	p := make([]float64, n)
+	for i := range p {
+		p[i] = 1
+	}
+
+	next := make([]float64, n)
+	for iteration := 0; iteration < btMaxIterations; iteration++ {
+		maxChange := 0.0
+		for i := 0; i < n; i++ {
+			denominator := BTPriorGames / (p[i] + 1)
+			for _, o := range games[i] {
+				denominator += o.games / (p[i] + p[o.index])
+			}
+			next[i] = (wins[i] + BTPriorGames/2) / denominator
+			maxChange = math.Max(maxChange, math.Abs(math.Log(next[i]/p[i])))
+		}
+		p, next = next, p
+		if maxChange < btTolerance {
+			break
+		}
+	}
+
+	// information matrix for theta_i = log p_i
+	information := make([][]float64, n)
+	for i := range information {
+		information[i] = make([]float64, n)
+		information[i][i] = BTPriorGames * p[i] / ((p[i] + 1) * (p[i] + 1))
+		for _, o := range games[i] {
+			w := o.games * p[i] * p[o.index] / ((p[i] + p[o.index]) * (p[i] + p[o.index]))
+			information[i][i] += w
+			information[i][o.index] -= w
+		}
+	}
+
+	covariance, ok := invert(information)
+	if !ok {
+		return nil, errors.New("bradley-terry information matrix is singular")
+	}
+
+	scale := 400 / math.Ln10
+	strengths := make([]Strength, n)
+	for i := range strengths {
+		strengths[i] = Strength{
+			Strength: p[i],
+			Rating:   InitialElo + scale*math.Log(p[i]),
+			StdError: scale * math.Sqrt(covariance[i][i]),
+		}
+	}
+	return strengths, nil
+}
+
+// invert returns the inverse of a square matrix by Gauss-Jordan elimination
+func invert(matrix [][]float64) ([][]float64, bool) {
+	n := len(matrix)
+	a := make([][]float64, n)
+	for i := range a {
+		a[i] = make([]float64, 2*n)
+		copy(a[i], matrix[i])
+		a[i][n+i] = 1
+	}
+
+	for col := 0; col < n; col++ {
+		pivot := col
+		for row := col + 1; row < n; row++ {
+			if math.Abs(a[row][col]) > math.Abs(a[pivot][col]) {
+				pivot = row
+			}
+		}
+		if math.Abs(a[pivot][col]) < 1e-12 {
+			return nil, false
+		}
+		a[col], a[pivot] = a[pivot], a[col]
+
+		inv := 1 / a[col][col]
+		for k := col; k < 2*n; k++ {
+			a[col][k] *= inv
+		}
+		for row := 0; row < n; row++ {
+			if row == col || a[row][col] == 0 {
+				continue
+			}
+			factor := a[row][col]
+			for k := col; k < 2*n; k++ {
+				a[row][k] -= factor * a[col][k]
+			}
+		}
+	}
+
+	inverse := make([][]float64, n)
+	for i := range inverse {
+		inverse[i] = a[i][n:]
+	}
+	return inverse, true
+}
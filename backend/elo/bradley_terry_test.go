@@ -0,0 +1,139 @@
+package elo
+
+import (
+	"math"
+	"math/rand"
+	"testing"
+)
+
+func TestBradleyTerryOrderIndependent(t *testing.T) {
+	comparisons := []Comparison{
+		{Winner: 0, Loser: 1},
+		{Winner: 0, Loser: 2, Margin: MaxMargin},
+		{Winner: 1, Loser: 2},
+		{Winner: 2, Loser: 3, Draw: true},
+		{Winner: 3, Loser: 1, Margin: MinMargin},
+		{Winner: 4, Loser: 0},
+		{Winner: 1, Loser: 4},
+		{Winner: 2, Loser: 0},
+	}
+	want, err := BradleyTerry(5, comparisons)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rng := rand.New(rand.NewSource(1))
+	for round := 0; round < 20; round++ {
+		shuffled := append([]Comparison(nil), comparisons...)
+		rng.Shuffle(len(shuffled), func(i, j int) { shuffled[i], shuffled[j] = shuffled[j], shuffled[i] })
+
+		got, err := BradleyTerry(5, shuffled)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := range got {
+			if got[i] != want[i] {
+				t.Fatalf("order %v: applicant %d got %+v, want %+v", shuffled, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestBradleyTerry(t *testing.T) {
+	tests := []struct {
+		name        string
+		n           int
+		comparisons []Comparison
+		check       func(t *testing.T, s []Strength)
+	}{
+		{"no comparisons", 3, nil, func(t *testing.T, s []Strength) {
+			for i := range s {
+				if math.Abs(s[i].Rating-InitialElo) > 1e-6 || !finitePositive(s[i].StdError) {
+					t.Errorf("applicant %d: %+v, want the initial rating with a finite error", i, s[i])
+				}
+			}
+		}},
+		{"one game is symmetric", 2, []Comparison{{Winner: 0, Loser: 1}}, func(t *testing.T, s []Strength) {
+			if math.Abs((s[0].Rating-InitialElo)-(InitialElo-s[1].Rating)) > 1e-6 {
+				t.Errorf("ratings %v and %v aren't symmetric around %d", s[0].Rating, s[1].Rating, InitialElo)
+			}
+			if math.Abs(s[0].StdError-s[1].StdError) > 1e-6 {
+				t.Errorf("standard errors %v and %v differ", s[0].StdError, s[1].StdError)
+			}
+		}},
+		{"a draw leaves both level", 2, []Comparison{{Winner: 0, Loser: 1, Draw: true}}, func(t *testing.T, s []Strength) {
+			if math.Abs(s[0].Rating-s[1].Rating) > 1e-6 {
+				t.Errorf("ratings %v and %v differ after a draw", s[0].Rating, s[1].Rating)
+			}
+		}},
+		{"unbeaten applicant stays finite", 3, []Comparison{{Winner: 0, Loser: 1}, {Winner: 0, Loser: 2}, {Winner: 0, Loser: 1}}, func(t *testing.T, s []Strength) {
+			if !finitePositive(s[0].Strength) || !finitePositive(s[0].StdError) || s[0].Rating <= s[1].Rating {
+				t.Errorf("unbeaten applicant got %+v", s[0])
+			}
+		}},
+		{"disconnected groups", 4, []Comparison{{Winner: 0, Loser: 1}, {Winner: 2, Loser: 3}}, func(t *testing.T, s []Strength) {
+			for i := range s {
+				if !finitePositive(s[i].StdError) {
+					t.Errorf("applicant %d has standard error %v", i, s[i].StdError)
+				}
+			}
+			if math.Abs(s[0].Rating-s[2].Rating) > 1e-6 || math.Abs(s[1].Rating-s[3].Rating) > 1e-6 {
+				t.Errorf("groups with the same record rated differently: %+v", s)
+			}
+		}},
+		{"more games, smaller error", 3, []Comparison{{Winner: 0, Loser: 1}, {Winner: 1, Loser: 0}, {Winner: 0, Loser: 1}, {Winner: 1, Loser: 0}}, func(t *testing.T, s []Strength) {
+			if s[0].StdError >= s[2].StdError {
+				t.Errorf("applicant with 4 games has error %v, one with none %v", s[0].StdError, s[2].StdError)
+			}
+		}},
+		{"no applicants", 0, nil, func(t *testing.T, s []Strength) {
+			if len(s) != 0 {
+				t.Errorf("got %d strengths", len(s))
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			strengths, err := BradleyTerry(tt.n, tt.comparisons)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(strengths) != tt.n {
+				t.Fatalf("got %d strengths for %d applicants", len(strengths), tt.n)
+			}
+			tt.check(t, strengths)
+		})
+	}
+}
+
+func TestBradleyTerryRejectsUnknownApplicants(t *testing.T) {
+	for _, c := range []Comparison{{Winner: 0, Loser: 2}, {Winner: -1, Loser: 0}, {Winner: 1, Loser: 1}} {
+		if _, err := BradleyTerry(2, []Comparison{c}); err == nil {
+			t.Errorf("comparison %+v was accepted", c)
+		}
+	}
+}
+
+func TestInvertSingular(t *testing.T) {
+	if _, ok := invert([][]float64{{1, 2}, {2, 4}}); ok {
+		t.Error("singular matrix was inverted")
+	}
+
+	inverse, ok := invert([][]float64{{4, 7}, {2, 6}})
+	want := [][]float64{{0.6, -0.7}, {-0.2, 0.4}}
+	if !ok {
+		t.Fatal("invertible matrix reported singular")
+	}
+	for i := range want {
+		for j := range want[i] {
+			if math.Abs(inverse[i][j]-want[i][j]) > 1e-9 {
+				t.Fatalf("inverse = %v, want %v", inverse, want)
+			}
+		}
+	}
+}
+
+func finitePositive(x float64) bool {
+	return x > 0 && !math.IsInf(x, 0) && !math.IsNaN(x)
+}
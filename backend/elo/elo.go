@@ -2,24 +2,53 @@ package elo
 
 import "math"
 
+// rating every applicant starts with, and is reset to before a replay
+const InitialElo = 1000
+
 const (
 	KFactorHigh = 32
 	KFactorMedium = 24
 	KFactorLow = 16
 )
 
-func CalculateElo(winnerElo, loserElo int, winner bool) (int, int) {
+// scores for the first applicant of a comparison, the second scores 1 minus this
+const (
+	Win  = 1.0
+	Draw = 0.5
+	Loss = 0.0
+)
+
+// margin of victory a reviewer can give a vote, 0 meaning none was given
+const (
+	MinMargin = 1
+	MaxMargin = 5
+)
+
+// MarginMultiplier scales a rating update by how strongly the reviewer preferred the
+// winner: 0.5 for a coin flip, 1 for the middle of the scale or no margin, 1.5 for a blowout
+func MarginMultiplier(margin int) float64 {
+	if margin < MinMargin || margin > MaxMargin {
+		return 1
+	}
+	mid := float64(MinMargin+MaxMargin) / 2
+	return 1 + (float64(margin)-mid)/float64(MaxMargin-MinMargin)
+}
+
+// CalculateElo returns the new ratings of a and b after a scored scoreA against b
+// with the given margin of victory
+func CalculateElo(aElo, bElo int, scoreA float64, margin int) (int, int) {
 	// probability winning for each
-	winnerProb := 1 / (1 + math.Pow(10, float64(loserElo - winnerElo)/400))
-	loserProb := 1 / (1 + math.Pow(10, float64(winnerElo - loserElo)/400))
+	aProb := 1 / (1 + math.Pow(10, float64(bElo-aElo)/400))
+	bProb := 1 / (1 + math.Pow(10, float64(aElo-bElo)/400))
 
-	winnerK := getKFactor(winnerElo)
-	loserK := getKFactor(loserElo)
+	multiplier := MarginMultiplier(margin)
+	aK := float64(getKFactor(aElo)) * multiplier
+	bK := float64(getKFactor(bElo)) * multiplier
 
-	newWinnerElo := winnerElo + int(float64(winnerK) * (1 - winnerProb))
-	newLoserElo := loserElo + int(float64(loserK) * (0 - loserProb))
+	newAElo := aElo + int(aK*(scoreA-aProb))
+	newBElo := bElo + int(bK*((1-scoreA)-bProb))
 
-	return newWinnerElo, newLoserElo
+	return newAElo, newBElo
 }
 
 func getKFactor(elo int) int {
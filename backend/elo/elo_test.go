@@ -0,0 +1,90 @@
+package elo
+
+import "testing"
+
+func TestCalculateEloScores(t *testing.T) {
+	tests := []struct {
+		name   string
+		a, b   int
+		scoreA float64
+		wantA  int
+		wantB  int
+	}{
+		{"win between equals", 1500, 1500, Win, 1516, 1484},
+		{"loss between equals", 1500, 1500, Loss, 1484, 1516},
+		{"draw between equals", 1500, 1500, Draw, 1500, 1500},
+		{"draw with the stronger applicant", 1400, 1600, Draw, 1408, 1594},
+	}
+
+	for _, tt := range tests {
+		a, b := CalculateElo(tt.a, tt.b, tt.scoreA, 0)
+		if a != tt.wantA || b != tt.wantB {
+			t.Errorf("%s: got %d, %d, want %d, %d", tt.name, a, b, tt.wantA, tt.wantB)
+		}
+	}
+}
+
+func TestMarginMultiplier(t *testing.T) {
+	tests := []struct {
+		margin int
+		want   float64
+	}{
+		{0, 1},
+		{MinMargin, 0.5},
+		{2, 0.75},
+		{3, 1},
+		{4, 1.25},
+		{MaxMargin, 1.5},
+		{MaxMargin + 1, 1},
+		{-1, 1},
+	}
+
+	for _, tt := range tests {
+		if got := MarginMultiplier(tt.margin); got != tt.want {
+			t.Errorf("MarginMultiplier(%d) = %v, want %v", tt.margin, got, tt.want)
+		}
+	}
+}
+
+func TestCalculateEloMargins(t *testing.T) {
+	tests := []struct {
+		name   string
+		margin int
+		wantA  int
+		wantB  int
+	}{
+		{"no margin", 0, 1516, 1484},
+		{"coin flip", MinMargin, 1508, 1492},
+		{"middle of the scale", 3, 1516, 1484},
+		{"blowout", MaxMargin, 1524, 1476},
+	}
+
+	for _, tt := range tests {
+		a, b := CalculateElo(1500, 1500, Win, tt.margin)
+		if a != tt.wantA || b != tt.wantB {
+			t.Errorf("%s: got %d, %d, want %d, %d", tt.name, a, b, tt.wantA, tt.wantB)
+		}
+	}
+}
+
+// each applicant's change uses the K of their own rating band
+func TestCalculateEloKFactors(t *testing.T) {
+	tests := []struct {
+		name  string
+		a, b  int
+		wantA int
+		wantB int
+	}{
+		{"new applicants", 1500, 1500, 1516, 1484},
+		{"established applicants", 1700, 1700, 1712, 1688},
+		{"top applicants", 2100, 2100, 2108, 2092},
+		{"new against established", 1590, 1610, 1606, 1598},
+	}
+
+	for _, tt := range tests {
+		a, b := CalculateElo(tt.a, tt.b, Win, 0)
+		if a != tt.wantA || b != tt.wantB {
+			t.Errorf("%s: got %d, %d, want %d, %d", tt.name, a, b, tt.wantA, tt.wantB)
+		}
+	}
+}
@@ -0,0 +1,114 @@
+package elo
+
+import "math"
+
+// Glicko-2 as described in http://www.glicko.net/glicko/glicko2.pdf, treating every
+// vote as its own rating period with a single game
+
+const (
+	glicko2Scale      = 173.7178
+	glicko2Epsilon    = 0.000001
+	InitialDeviation  = 350
+	InitialVolatility = 0.06
+)
+
+type Glicko2 struct {
+	// Tau constrains how much volatility can change per game, 0.3 to 1.2 is sensible
+	Tau float64
+}
+
+func NewGlicko2() Glicko2 {
+	return Glicko2{Tau: 0.5}
+}
+
+func (Glicko2) Name() string {
+	return SystemGlicko2
+}
+
+func (Glicko2) Initial() Rating {
+	return Rating{
+		Value:      InitialElo,
+		Deviation:  InitialDeviation,
+		Volatility: InitialVolatility,
+	}
+}
+
+func (g Glicko2) Update(a, b Rating, scoreA float64, margin int) (Rating, Rating) {
+	multiplier := MarginMultiplier(margin)
+	return g.rate(a, []glicko2Game{{b, scoreA}}, multiplier), g.rate(b, []glicko2Game{{a, 1 - scoreA}}, multiplier)
+}
+
+// glicko2Game is one game of a rating period, scored from the rated player's side
+type glicko2Game struct {
+	opponent Rating
+	score    float64
+}
+
+// rate returns player's new rating after a rating period of games. The margin
+// multiplier scales the rating change only, deviation and volatility update as usual
+func (g Glicko2) rate(player Rating, games []glicko2Game, multiplier float64) Rating {
+	mu := player.Value / glicko2Scale
+	phi := player.Deviation / glicko2Scale
+
+	var information, improvement float64
+	for _, game := range games {
+		muJ := game.opponent.Value / glicko2Scale
+		phiJ := game.opponent.Deviation / glicko2Scale
+
+		gPhiJ := 1 / math.Sqrt(1+3*phiJ*phiJ/(math.Pi*math.Pi))
+		expected := 1 / (1 + math.Exp(-gPhiJ*(mu-muJ)))
+		information += gPhiJ * gPhiJ * expected * (1 - expected)
+		improvement += gPhiJ * (game.score - expected)
+	}
+	v := 1 / information
+	delta := v * improvement
+
+	sigma := g.volatility(phi, player.Volatility, v, delta)
+
+	phiStar := math.Sqrt(phi*phi + sigma*sigma)
+	newPhi := 1 / math.Sqrt(1/(phiStar*phiStar)+1/v)
+	newMu := mu + multiplier*newPhi*newPhi*improvement
+
+	return Rating{
+		Value:      newMu * glicko2Scale,
+		Deviation:  newPhi * glicko2Scale,
+		Volatility: sigma,
+	}
+}
+
+// volatility finds the new volatility with the Illinois algorithm (step 5 of the paper)
+func (g Glicko2) volatility(phi, sigma, v, delta float64) float64 {
+	a := math.Log(sigma * sigma)
+	tau2 := g.Tau * g.Tau
+	f := func(x float64) float64 {
+		ex := math.Exp(x)
+		d := phi*phi + v + ex
+		return ex*(delta*delta-phi*phi-v-ex)/(2*d*d) - (x-a)/tau2
+	}
+
+	A := a
+	var B float64
+	if delta*delta > phi*phi+v {
+		B = math.Log(delta*delta - phi*phi - v)
+	} else {
+		k := 1.0
+		for f(a-k*g.Tau) < 0 {
+			k++
+		}
+		B = a - k*g.Tau
+	}
+
+	fA, fB := f(A), f(B)
+	for math.Abs(B-A) > glicko2Epsilon {
+		C := A + (A-B)*fA/(fB-fA)
+		fC := f(C)
+		if fC*fB <= 0 {
+			A, fA = B, fB
+		} else {
+			fA /= 2
+		}
+		B, fB = C, fC
+	}
+
+	return math.Exp(A / 2)
+}
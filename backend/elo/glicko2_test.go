@@ -0,0 +1,72 @@
+package elo
+
+import (
+	"math"
+	"testing"
+)
+
+// the worked example from section 3 of the Glicko-2 paper
+func TestGlicko2PaperExample(t *testing.T) {
+	player := Rating{Value: 1500, Deviation: 200, Volatility: 0.06}
+	games := []glicko2Game{
+		{Rating{Value: 1400, Deviation: 30}, Win},
+		{Rating{Value: 1550, Deviation: 100}, Loss},
+		{Rating{Value: 1700, Deviation: 300}, Loss},
+	}
+
+	got := NewGlicko2().rate(player, games, 1)
+
+	tests := []struct {
+		name      string
+		got, want float64
+		tolerance float64
+	}{
+		{"rating", got.Value, 1464.06, 0.01},
+		{"deviation", got.Deviation, 151.52, 0.01},
+		{"volatility", got.Volatility, 0.05999, 0.00001},
+	}
+	for _, tt := range tests {
+		if math.Abs(tt.got-tt.want) > tt.tolerance {
+			t.Errorf("%s = %v, want %v", tt.name, tt.got, tt.want)
+		}
+	}
+}
+
+func TestGlicko2Update(t *testing.T) {
+	g := NewGlicko2()
+	established := Rating{Value: 1600, Deviation: 60, Volatility: InitialVolatility}
+
+	tests := []struct {
+		name   string
+		a, b   Rating
+		scoreA float64
+		margin int
+		check  func(a, b Rating) bool
+	}{
+		{"draw between equals moves neither", g.Initial(), g.Initial(), Draw, 0,
+			func(a, b Rating) bool { return a.Value == InitialElo && b.Value == InitialElo }},
+		{"every game lowers deviation", g.Initial(), g.Initial(), Win, 0,
+			func(a, b Rating) bool { return a.Deviation < InitialDeviation && b.Deviation < InitialDeviation }},
+		{"gains and losses mirror between equals", g.Initial(), g.Initial(), Win, 0,
+			func(a, b Rating) bool { return math.Abs((a.Value-InitialElo)+(b.Value-InitialElo)) < 1e-9 }},
+		{"an uncertain rating moves more", g.Initial(), established, Loss, 0,
+			func(a, b Rating) bool { return InitialElo-a.Value > b.Value-established.Value }},
+		{"upset moves more than expected result", g.Initial(), established, Win, 0,
+			func(a, b Rating) bool {
+				expectedA, _ := g.Update(g.Initial(), established, Loss, 0)
+				return a.Value-InitialElo > InitialElo-expectedA.Value
+			}},
+		{"a blowout moves more than a coin flip", g.Initial(), g.Initial(), Win, MaxMargin,
+			func(a, b Rating) bool {
+				close, _ := g.Update(g.Initial(), g.Initial(), Win, MinMargin)
+				return a.Value > close.Value
+			}},
+	}
+
+	for _, tt := range tests {
+		a, b := g.Update(tt.a, tt.b, tt.scoreA, tt.margin)
+		if !tt.check(a, b) {
+			t.Errorf("%s: got a = %+v, b = %+v", tt.name, a, b)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package elo
+
+import "math"
+
+const (
+	SystemElo     = "elo"
+	SystemGlicko2 = "glicko2"
+)
+
+// Rating is an applicant's state under a rating system. Value is on the familiar
+// Elo scale; Deviation and Volatility are only tracked by systems that model uncertainty
+type Rating struct {
+	Value      float64
+	Deviation  float64
+	Volatility float64
+}
+
+// Elo rounds Value to the integer stored on applicants
+func (r Rating) Elo() int {
+	return int(math.Round(r.Value))
+}
+
+// System is a pluggable rating engine that can be selected per project
+type System interface {
+	Name() string
+	Initial() Rating
+	// Update rates a game where a scored scoreA (Win, Draw or Loss) against b,
+	// weighted by the reviewer's margin of victory (0 for none)
+	Update(a, b Rating, scoreA float64, margin int) (Rating, Rating)
+}
+
+// GetSystem looks up a rating system by name, an empty name meaning the default Elo
+func GetSystem(name string) (System, bool) {
+	switch name {
+	case "", SystemElo:
+		return EloSystem{}, true
+	case SystemGlicko2:
+		return NewGlicko2(), true
+	default:
+		return nil, false
+	}
+}
+
+// EloSystem adapts CalculateElo to the System interface
+type EloSystem struct{}
+
+func (EloSystem) Name() string {
+	return SystemElo
+}
+
+func (EloSystem) Initial() Rating {
+	return Rating{Value: InitialElo}
+}
+
+func (EloSystem) Update(a, b Rating, scoreA float64, margin int) (Rating, Rating) {
+	newAElo, newBElo := CalculateElo(int(a.Value), int(b.Value), scoreA, margin)
+	a.Value = float64(newAElo)
+	b.Value = float64(newBElo)
+	return a, b
+}
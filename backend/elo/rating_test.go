@@ -0,0 +1,55 @@
+package elo
+
+import (
+	"math"
+	"testing"
+)
+
+func TestSystems(t *testing.T) {
+	tests := []struct {
+		name string
+		want string
+	}{
+		{"", SystemElo},
+		{SystemElo, SystemElo},
+		{SystemGlicko2, SystemGlicko2},
+	}
+
+	for _, tt := range tests {
+		system, ok := GetSystem(tt.name)
+		if !ok {
+			t.Fatalf("GetSystem(%q) not found", tt.name)
+		}
+		if system.Name() != tt.want {
+			t.Errorf("GetSystem(%q).Name() = %q, want %q", tt.name, system.Name(), tt.want)
+		}
+
+		initial := system.Initial()
+		if initial.Value != InitialElo {
+			t.Errorf("%s: initial rating %v, want %d", tt.want, initial.Value, InitialElo)
+		}
+
+		winner, loser := system.Update(initial, initial, Win, 0)
+		if winner.Value <= initial.Value || loser.Value >= initial.Value {
+			t.Errorf("%s: win moved ratings to %v and %v", tt.want, winner.Value, loser.Value)
+		}
+
+		a, b := system.Update(initial, initial, Draw, 0)
+		if a.Value != initial.Value || b.Value != initial.Value {
+			t.Errorf("%s: draw between equals moved ratings to %v and %v", tt.want, a.Value, b.Value)
+		}
+
+		// a loss for a is a win for b
+		stronger := initial
+		stronger.Value += 200
+		lostA, wonB := system.Update(initial, stronger, Loss, 0)
+		wonB2, lostA2 := system.Update(stronger, initial, Win, 0)
+		if math.Abs(lostA.Value-lostA2.Value) > 1e-9 || math.Abs(wonB.Value-wonB2.Value) > 1e-9 {
+			t.Errorf("%s: scoring a loss for a differs from a win for b", tt.want)
+		}
+	}
+
+	if _, ok := GetSystem("trueskill"); ok {
+		t.Error("unknown rating system was found")
+	}
+}
@@ -7,20 +7,30 @@ import (
 )
 
 type Applicant struct {
-	ID            primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
-	FirstName     string              `json:"firstName" bson:"firstName"`
-	LastName      string              `json:"lastName" bson:"lastName"`
-	Major         string              `json:"major" bson:"major"`
-	Year          string              `json:"year" bson:"year"`
-	Timestamp     string              `json:"timestamp" bson:"timestamp"`
-	ProjectID     primitive.ObjectID   `json:"project_id" bson:"project_id"`
-	Wins          int                 `json:"wins" bson:"wins"`
-	Losses        int                 `json:"losses" bson:"losses"`
-	Elo           int                 `json:"elo" bson:"elo"`
-	MatchesPlayed []primitive.ObjectID `json:"matches_played" bson:"matches_played"`
-	Resume        *FileInfo           `json:"resume,omitempty" bson:"resume,omitempty"`
-	CoverLetter   *FileInfo           `json:"coverLetter,omitempty" bson:"coverLetter,omitempty"`
-	Image         *FileInfo           `json:"image,omitempty" bson:"image,omitempty"`
+	ID              primitive.ObjectID   `json:"_id" bson:"_id,omitempty"`
+	FirstName       string               `json:"firstName" bson:"firstName"`
+	LastName        string               `json:"lastName" bson:"lastName"`
+	Major           string               `json:"major" bson:"major"`
+	Year            string               `json:"year" bson:"year"`
+	Email           string               `json:"email,omitempty" bson:"email,omitempty"`
+	Timestamp       string               `json:"timestamp" bson:"timestamp"`
+	ProjectID       primitive.ObjectID   `json:"project_id" bson:"project_id"`
+	Wins            int                  `json:"wins" bson:"wins"`
+	Losses          int                  `json:"losses" bson:"losses"`
+	Draws           int                  `json:"draws" bson:"draws"`
+	Elo             int                  `json:"elo" bson:"elo"`
+	Rating          float64              `json:"rating,omitempty" bson:"rating,omitempty"`
+	RatingDeviation float64              `json:"rating_deviation,omitempty" bson:"rating_deviation,omitempty"`
+	Volatility      float64              `json:"volatility,omitempty" bson:"volatility,omitempty"`
+	MatchesPlayed   []primitive.ObjectID `json:"matches_played" bson:"matches_played"`
+	Resume          *FileInfo            `json:"resume,omitempty" bson:"resume,omitempty"`
+	CoverLetter     *FileInfo            `json:"coverLetter,omitempty" bson:"coverLetter,omitempty"`
+	Image           *FileInfo            `json:"image,omitempty" bson:"image,omitempty"`
+	// every answer not mapped onto a field above, keyed by field name or question
+	Answers map[string]interface{} `json:"answers,omitempty" bson:"answers,omitempty"`
+	// set when the submission looks like another applicant's resubmission but wasn't
+	// close enough to replace it, see ApplicantController.Merge
+	DuplicateOf *primitive.ObjectID `json:"duplicateOf,omitempty" bson:"duplicate_of,omitempty"`
 }
 
 type FileInfo struct {
@@ -30,7 +40,8 @@ type FileInfo struct {
 	DriveFileID string    `json:"driveFileId" bson:"driveFileId"`
 	UniqueName  string    `json:"uniqueName" bson:"uniqueName"`
 	UploadedAt  time.Time `json:"uploadedAt" bson:"uploadedAt"`
-	Data        string    `json:"data,omitempty" bson:"-"`
+	// where the file can be downloaded from, filled in when an applicant is served
+	URL string `json:"url,omitempty" bson:"-"`
 }
 
 type FormResponses struct {
@@ -42,4 +53,4 @@ type FormResponses struct {
 type Response struct {
 	Question string      `json:"question"`
 	Answer   interface{} `json:"answer"`
-}
\ No newline at end of file
+}
@@ -0,0 +1,20 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Lease reserves a served pair for one reviewer until ExpiresAt, so nobody else is
+// shown the same pair while they decide. The pair only counts as played once a vote
+// is cast with the lease
+type Lease struct {
+	ID         primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	ProjectID  primitive.ObjectID `json:"project_id" bson:"project_id"`
+	ApplicantA primitive.ObjectID `json:"applicant_a" bson:"applicant_a"`
+	ApplicantB primitive.ObjectID `json:"applicant_b" bson:"applicant_b"`
+	ReviewerID string             `json:"reviewer_id" bson:"reviewer_id"`
+	CreatedAt  time.Time          `json:"created_at" bson:"created_at"`
+	ExpiresAt  time.Time          `json:"expires_at" bson:"expires_at"`
+}
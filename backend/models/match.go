@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	OutcomeWin  = "win"
+	OutcomeDraw = "draw"
+	// the pair was presented but the reviewer couldn't decide, ratings are untouched
+	OutcomeSkip = "skip"
+)
+
+// Match is a single recorded vote between two applicants of a project. For draws
+// and skips WinnerID and LoserID are simply the two applicants in the order shown.
+// IdempotencyKey is set when the vote came with a key or lease, the reviewer's retries
+// with the same key in the project get this match back instead of voting again
+type Match struct {
+	ID              primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	ProjectID       primitive.ObjectID `json:"project_id" bson:"project_id"`
+	WinnerID        primitive.ObjectID `json:"winner_id" bson:"winner_id"`
+	LoserID         primitive.ObjectID `json:"loser_id" bson:"loser_id"`
+	Outcome         string             `json:"outcome" bson:"outcome"`
+	Margin          int                `json:"margin,omitempty" bson:"margin,omitempty"`
+	ReviewerID      string             `json:"reviewer_id" bson:"reviewer_id"`
+	WinnerEloBefore int                `json:"winner_elo_before" bson:"winner_elo_before"`
+	LoserEloBefore  int                `json:"loser_elo_before" bson:"loser_elo_before"`
+	WinnerEloAfter  int                `json:"winner_elo_after" bson:"winner_elo_after"`
+	LoserEloAfter   int                `json:"loser_elo_after" bson:"loser_elo_after"`
+	Pass            int                `json:"pass" bson:"pass"`
+	IdempotencyKey  string             `json:"idempotency_key,omitempty" bson:"idempotency_key,omitempty"`
+	CreatedAt       time.Time          `json:"created_at" bson:"created_at"`
+}
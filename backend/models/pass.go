@@ -0,0 +1,18 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Pass is one run through a project's pairs. Starting a new pass resets pairing history
+// so every pair can be reviewed again, while votes from earlier passes stay on record
+type Pass struct {
+	ID        primitive.ObjectID `json:"_id" bson:"_id,omitempty"`
+	ProjectID primitive.ObjectID `json:"project_id" bson:"project_id"`
+	Number    int                `json:"number" bson:"number"`
+	StartedBy string             `json:"started_by" bson:"started_by"`
+	Note      string             `json:"note,omitempty" bson:"note,omitempty"`
+	StartedAt time.Time          `json:"started_at" bson:"started_at"`
+}
@@ -1,11 +1,71 @@
 package models
 
-import "go.mongodb.org/mongo-driver/bson/primitive"
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// lifecycle of a project, in order. Applications are only taken while accepting
+// applications, and comparisons only run while reviewing
+const (
+	ProjectDraft                 = "draft"
+	ProjectAcceptingApplications = "accepting_applications"
+	ProjectReviewing             = "reviewing"
+	ProjectFinalized             = "finalized"
+	ProjectArchived              = "archived"
+)
+
+const (
+	ComparisonsOpen = "open"
+	// every pair has been reviewed, comparisons stop until the history is reset
+	ComparisonsExhausted = "exhausted"
+)
 
 type Project struct {
 	ID                   primitive.ObjectID `bson:"_id,omitempty" json:"id"`
 	Name                 string             `bson:"name" json:"name"`
+	Status               string             `bson:"status" json:"status"`
 	TotalApplicants      int                `bson:"totalApplicants" json:"totalApplicants"`
 	CompletedComparisons int                `bson:"completedComparisons" json:"completedComparisons"`
 	TotalComparisons     int                `bson:"totalComparisons" json:"totalComparisons"`
+	RatingSystem         string             `bson:"ratingSystem" json:"ratingSystem"`
+	PairingStrategy      string             `bson:"pairingStrategy" json:"pairingStrategy"`
+	// how many distinct reviewers should judge each pair, 1 if unset
+	ReviewsPerPair int `bson:"reviewsPerPair" json:"reviewsPerPair"`
+	// votes before this are left out of pairing history
+	HistoryResetAt *time.Time `bson:"historyResetAt,omitempty" json:"historyResetAt,omitempty"`
+	// the pass votes are currently recorded under, see Pass
+	Pass             int    `bson:"pass" json:"pass"`
+	ComparisonStatus string `bson:"comparisonStatus" json:"comparisonStatus"`
+	// external form IDs whose submissions belong to this project
+	FormIDs []string `bson:"formIds,omitempty" json:"formIds"`
+	// how the form's questions are read, the default form layout if empty
+	FormSchema []FormField `bson:"formSchema,omitempty" json:"formSchema"`
+	// keys the form listener checks request signatures against, never sent to clients
+	WebhookSecrets []WebhookSecret `bson:"webhookSecrets,omitempty" json:"-"`
+}
+
+// WebhookSecret signs form submissions. Rotating a project's secret keeps the old one
+// valid until ExpiresAt, so the form script can be updated without dropping submissions
+type WebhookSecret struct {
+	Secret    string     `bson:"secret" json:"secret"`
+	CreatedAt time.Time  `bson:"createdAt" json:"createdAt"`
+	ExpiresAt *time.Time `bson:"expiresAt,omitempty" json:"expiresAt,omitempty"`
+}
+
+const (
+	FieldTypeString  = "string"
+	FieldTypeNumber  = "number"
+	FieldTypeBoolean = "boolean"
+	FieldTypeList    = "list"
+	FieldTypeFile    = "file"
+)
+
+// FormField maps a form question onto an applicant field. Fields that aren't built into
+// Applicant are stored under their name in the applicant's answers
+type FormField struct {
+	Question string `bson:"question" json:"question"`
+	Field    string `bson:"field" json:"field"`
+	Type     string `bson:"type" json:"type"`
 }
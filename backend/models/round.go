@@ -0,0 +1,35 @@
+package models
+
+import (
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+const (
+	PairingPending   = "pending"
+	PairingServed    = "served"
+	PairingCompleted = "completed"
+)
+
+// Round is one Swiss-system round of a project: every applicant appears in exactly
+// one pairing, except the bye when the count is odd
+type Round struct {
+	ID          primitive.ObjectID  `json:"_id" bson:"_id,omitempty"`
+	ProjectID   primitive.ObjectID  `json:"project_id" bson:"project_id"`
+	Number      int                 `json:"number" bson:"number"`
+	Pass        int                 `json:"pass" bson:"pass"`
+	Pairings    []RoundPairing      `json:"pairings" bson:"pairings"`
+	Bye         *primitive.ObjectID `json:"bye,omitempty" bson:"bye,omitempty"`
+	CreatedAt   time.Time           `json:"created_at" bson:"created_at"`
+	CompletedAt *time.Time          `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+}
+
+type RoundPairing struct {
+	ApplicantA primitive.ObjectID  `json:"applicant_a" bson:"applicant_a"`
+	ApplicantB primitive.ObjectID  `json:"applicant_b" bson:"applicant_b"`
+	Status     string              `json:"status" bson:"status"`
+	ServedAt   *time.Time          `json:"served_at,omitempty" bson:"served_at,omitempty"`
+	ReleasedAt *time.Time          `json:"released_at,omitempty" bson:"released_at,omitempty"`
+	MatchID    *primitive.ObjectID `json:"match_id,omitempty" bson:"match_id,omitempty"`
+}
@@ -0,0 +1,58 @@
+package pairing
+
+import (
+	"context"
+	"sync"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Cache keeps one Pool per project in memory, loading it on first use. Anything that
+// changes a project's applicants or history in ways the pool can't follow (new
+// applicants, undo, replay, history resets) should Invalidate it
+type Cache struct {
+	mu    sync.Mutex
+	pools map[primitive.ObjectID]*Pool
+	load  func(ctx context.Context, projectID primitive.ObjectID) (*Pool, error)
+}
+
+func NewCache(load func(ctx context.Context, projectID primitive.ObjectID) (*Pool, error)) *Cache {
+	return &Cache{
+		pools: make(map[primitive.ObjectID]*Pool),
+		load:  load,
+	}
+}
+
+// Get returns the project's pool, loading it if it isn't cached
+func (c *Cache) Get(ctx context.Context, projectID primitive.ObjectID) (*Pool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if pool, ok := c.pools[projectID]; ok {
+		return pool, nil
+	}
+
+	pool, err := c.load(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	c.pools[projectID] = pool
+	return pool, nil
+}
+
+// Peek returns the project's pool only if it is already cached
+func (c *Cache) Peek(projectID primitive.ObjectID) (*Pool, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	pool, ok := c.pools[projectID]
+	return pool, ok
+}
+
+// Invalidate drops the project's pool so the next Get reloads it
+func (c *Cache) Invalidate(projectID primitive.ObjectID) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	delete(c.pools, projectID)
+}
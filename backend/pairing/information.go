@@ -0,0 +1,54 @@
+package pairing
+
+import "math"
+
+const (
+	// how many available neighbours below each applicant are scored, and how far down
+	// the order to look for them. Expected gain falls off quickly with rating gap, so
+	// pairs further apart are never the most informative
+	informationWindow = 8
+	informationScan   = 64
+)
+
+// mostInformative returns the available pair outside seen with the largest expected
+// reduction in rating variance, or ok false if none is found within the scan window
+func (p *Pool) mostInformative(seen map[pairKey]bool) (*entry, *entry, bool) {
+	var bestA, bestB *entry
+	bestGain := 0.0
+
+	for i, a := range p.order {
+		scored := 0
+		for j := i + 1; j < len(p.order) && j <= i+informationScan && scored < informationWindow; j++ {
+			b := p.order[j]
+			if _, ok := seen[keyFor(a.id, b.id)]; ok || p.isTaken(a, b) {
+				continue
+			}
+			scored++
+
+			if gain := informationGain(a, b); bestA == nil || gain > bestGain {
+				bestA, bestB, bestGain = a, b, gain
+			}
+		}
+	}
+
+	return bestA, bestB, bestA != nil
+}
+
+// informationGain is the expected drop in the two applicants' combined rating variance
+// from one more comparison between them, using the Glicko approximation on the Elo scale:
+// a game carries q²·g²·p·(1-p) of information, most when the outcome is a coin flip and
+// the ratings are uncertain
+func informationGain(a, b *entry) float64 {
+	varA := a.deviation * a.deviation
+	varB := b.deviation * b.deviation
+	if varA <= 0 || varB <= 0 {
+		return 0
+	}
+
+	q := math.Ln10 / 400
+	g := 1 / math.Sqrt(1+3*q*q*(varA+varB)/(math.Pi*math.Pi))
+	expected := 1 / (1 + math.Pow(10, -g*float64(a.elo-b.elo)/400))
+	information := q * q * g * g * expected * (1 - expected)
+
+	return varA - 1/(1/varA+information) + varB - 1/(1/varB+information)
+}
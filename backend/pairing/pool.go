@@ -0,0 +1,522 @@
+package pairing
+
+import (
+	"bytes"
+	"container/heap"
+	"sort"
+	"sync"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// Pool holds one project's applicants sorted by rating, along with the pairs that have
+// been judged by enough reviewers, the pairs currently leased to a reviewer and what each
+// reviewer has already seen. Every applicant keeps a candidate: its nearest available
+// neighbour below it in the order. Candidates live in a min-heap keyed on rating
+// difference, so the closest available pair is at the top and only the candidates
+// touched by a vote or a lease need recomputing, instead of scanning every pair on
+// every request. Reviewers who have been shown pairs get a view of their own, whose
+// candidates also skip the pairs they've seen.
+type Pool struct {
+	mu             sync.Mutex
+	strategy       string
+	reviewsPerPair int
+	order          []*entry
+	entries        map[primitive.ObjectID]*entry
+	played         map[pairKey]struct{}
+	reserved       map[pairKey]time.Time
+	// judgements of each pair by distinct reviewers
+	reviews map[pairKey]int
+	// pairs each reviewer has been shown, true once they judged it
+	seen map[string]map[pairKey]bool
+	// candidates for anonymous reviewers, and for each reviewer who has seen a pair
+	all   *view
+	views map[string]*view
+	seq   int
+}
+
+type Applicant struct {
+	ID  primitive.ObjectID
+	Elo int
+	// Deviation is the uncertainty of Elo, used by StrategyInformation
+	Deviation float64
+}
+
+// Review is one reviewer's vote on a pair. Skips are seen by the reviewer but not
+// Judged, so they don't count towards the pair's reviews. Anonymous reviews aren't
+// tracked per reviewer, and only count when a single review completes the pair: with
+// more, nothing tells one anonymous reviewer from another
+type Review struct {
+	A, B     primitive.ObjectID
+	Reviewer string
+	Judged   bool
+}
+
+type entry struct {
+	id        primitive.ObjectID
+	elo       int
+	deviation float64
+	pos       int
+	// where the entry's candidate state lives in each view
+	index int
+}
+
+type pairKey struct {
+	a, b primitive.ObjectID
+}
+
+func keyFor(a, b primitive.ObjectID) pairKey {
+	if bytes.Compare(a[:], b[:]) > 0 {
+		a, b = b, a
+	}
+	return pairKey{a, b}
+}
+
+// view is one set of candidates over the pool's order. A pair is available to it when
+// it is neither played, leased nor in seen
+type view struct {
+	seen map[pairKey]bool
+	// each entry's candidate partner, the entries whose candidate it is, and a counter
+	// that turns its earlier candidates in the heap stale
+	target     []*entry
+	targetedBy []map[*entry]struct{}
+	generation []int
+	heap       candidateHeap
+}
+
+// NewPool builds a pool from the project's applicants and the reviews recorded so far,
+// serving pairs with the given strategy until each has been judged by reviewsPerPair
+// distinct reviewers
+func NewPool(strategy string, reviewsPerPair int, applicants []Applicant, reviews []Review) *Pool {
+	if reviewsPerPair < 1 {
+		reviewsPerPair = 1
+	}
+
+	p := &Pool{
+		strategy:       strategy,
+		reviewsPerPair: reviewsPerPair,
+		order:          make([]*entry, 0, len(applicants)),
+		entries:        make(map[primitive.ObjectID]*entry, len(applicants)),
+		played:         make(map[pairKey]struct{}),
+		reserved:       make(map[pairKey]time.Time),
+		reviews:        make(map[pairKey]int),
+		seen:           make(map[string]map[pairKey]bool),
+		views:          make(map[string]*view),
+	}
+
+	for i, applicant := range applicants {
+		e := &entry{
+			id:        applicant.ID,
+			elo:       applicant.Elo,
+			deviation: applicant.Deviation,
+			index:     i,
+		}
+		p.order = append(p.order, e)
+		p.entries[applicant.ID] = e
+	}
+	for _, review := range reviews {
+		p.record(review)
+	}
+
+	sort.SliceStable(p.order, func(i, j int) bool { return less(p.order[i], p.order[j]) })
+	for i, e := range p.order {
+		e.pos = i
+	}
+	p.all = p.newView(nil)
+	return p
+}
+
+// Size is the number of applicants in the pool
+func (p *Pool) Size() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.order)
+}
+
+// TakeNext reserves the next pair the reviewer hasn't seen under the pool's strategy
+// until expires, higher rated applicant first, so concurrent callers get different
+// pairs. Reservations past their expiry are released first. ok is false when no pair
+// is available to the reviewer
+func (p *Pool) TakeNext(reviewer string, now, expires time.Time) (primitive.ObjectID, primitive.ObjectID, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	for key, expiry := range p.reserved {
+		if !expiry.After(now) {
+			p.release(key)
+		}
+	}
+
+	seen := p.seen[reviewer]
+	if p.strategy == StrategyInformation {
+		if a, b, ok := p.mostInformative(seen); ok {
+			p.reserve(a, b, expires)
+			return a.id, b.id, true
+		}
+	}
+
+	// closest-rated available pair, also the fallback once no informative pair is
+	// left near anyone in the order
+	if c, ok := p.viewFor(reviewer).top(); ok {
+		p.reserve(c.a, c.b, expires)
+		return c.a.id, c.b.id, true
+	}
+	return primitive.NilObjectID, primitive.NilObjectID, false
+}
+
+// Available reports whether any pair is still neither judged enough nor leased, to
+// anyone. Expired leases are only released by TakeNext
+func (p *Pool) Available() bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	_, ok := p.all.top()
+	return ok
+}
+
+// Reserved is the number of pairs currently leased out
+func (p *Pool) Reserved() int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return len(p.reserved)
+}
+
+// Reserve holds a pair that was leased outside of TakeNext, e.g. before a reload
+func (p *Pool) Reserve(a, b primitive.ObjectID, expires time.Time) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	ea, eb, ok := p.pair(a, b)
+	if !ok {
+		return
+	}
+	p.reserve(ea, eb, expires)
+}
+
+// Release makes a leased pair available again without it having been played
+func (p *Pool) Release(a, b primitive.ObjectID) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	p.release(keyFor(a, b))
+}
+
+// Record adds a vote on a pair, ending any lease on it. The pair stops being served
+// once enough distinct reviewers have judged it, and to its reviewer straight away
+func (p *Pool) Record(review Review) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	key := keyFor(review.A, review.B)
+	_, leased := p.reserved[key]
+	delete(p.reserved, key)
+
+	completed := p.record(review)
+	ea, eb, ok := p.pair(review.A, review.B)
+	if !ok {
+		return
+	}
+	if completed {
+		p.unavailable(ea, eb)
+		return
+	}
+	if leased {
+		p.reopen(ea, eb)
+	}
+	if v, ok := p.views[review.Reviewer]; ok {
+		v.unavailable(p, ea, eb)
+	}
+}
+
+// SetRating moves an applicant to its new place in the order after a vote. This is
+// O(n) per call and view: besides sliding the entry, it checks every entry above its new
+// place for a candidate that now skips over it. The check per entry is a comparison, and
+// only the entries that should now pick the moved one are updated, so a vote costs a few
+// µs per view at a few hundred applicants, see BenchmarkPoolVote
+func (p *Pool) SetRating(id primitive.ObjectID, elo int, deviation float64) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	e, ok := p.entries[id]
+	if !ok {
+		return
+	}
+	e.deviation = deviation
+	if e.elo == elo {
+		return
+	}
+
+	// slide the entry to its new position, shifting the ones in between
+	e.elo = elo
+	for e.pos > 0 && less(e, p.order[e.pos-1]) {
+		p.swap(e.pos, e.pos-1)
+	}
+	for e.pos < len(p.order)-1 && less(p.order[e.pos+1], e) {
+		p.swap(e.pos, e.pos+1)
+	}
+
+	p.all.moved(p, e)
+	for _, v := range p.views {
+		v.moved(p, e)
+	}
+}
+
+// viewFor returns the candidates for a reviewer, setting up a view of their own once
+// they've seen a pair
+func (p *Pool) viewFor(reviewer string) *view {
+	seen, ok := p.seen[reviewer]
+	if !ok {
+		return p.all
+	}
+	v, ok := p.views[reviewer]
+	if !ok {
+		v = p.newView(seen)
+		p.views[reviewer] = v
+	}
+	return v
+}
+
+func (p *Pool) newView(seen map[pairKey]bool) *view {
+	v := &view{
+		seen:       seen,
+		target:     make([]*entry, len(p.order)),
+		targetedBy: make([]map[*entry]struct{}, len(p.order)),
+		generation: make([]int, len(p.order)),
+	}
+	for i := range v.targetedBy {
+		v.targetedBy[i] = make(map[*entry]struct{})
+	}
+	for _, e := range p.order {
+		v.refresh(p, e, e.pos+1)
+	}
+	return v
+}
+
+func (p *Pool) pair(a, b primitive.ObjectID) (*entry, *entry, bool) {
+	ea, ok := p.entries[a]
+	if !ok {
+		return nil, nil, false
+	}
+	eb, ok := p.entries[b]
+	if !ok {
+		return nil, nil, false
+	}
+	return ea, eb, true
+}
+
+func (p *Pool) reserve(a, b *entry, expires time.Time) {
+	p.reserved[keyFor(a.id, b.id)] = expires
+	p.unavailable(a, b)
+}
+
+// unavailable moves every view's candidates off a pair that can no longer be served
+func (p *Pool) unavailable(a, b *entry) {
+	p.all.unavailable(p, a, b)
+	for _, v := range p.views {
+		v.unavailable(p, a, b)
+	}
+}
+
+// reopen moves candidates onto a pair that has become available again, in the views
+// it's available to
+func (p *Pool) reopen(a, b *entry) {
+	p.all.reopen(p, a, b)
+	for _, v := range p.views {
+		v.reopen(p, a, b)
+	}
+}
+
+// record counts a review and reports whether it completed the pair
+func (p *Pool) record(review Review) bool {
+	key := keyFor(review.A, review.B)
+
+	counted := review.Judged && p.reviewsPerPair == 1
+	if review.Reviewer != "" {
+		seen, ok := p.seen[review.Reviewer]
+		if !ok {
+			seen = make(map[pairKey]bool)
+			p.seen[review.Reviewer] = seen
+		}
+		// a reviewer only counts once per pair
+		counted = review.Judged && !seen[key]
+		seen[key] = seen[key] || review.Judged
+	}
+	if counted {
+		p.reviews[key]++
+	}
+
+	if _, ok := p.played[key]; ok || p.reviews[key] < p.reviewsPerPair {
+		return false
+	}
+	p.played[key] = struct{}{}
+	return true
+}
+
+func (p *Pool) release(key pairKey) {
+	if _, ok := p.reserved[key]; !ok {
+		return
+	}
+	delete(p.reserved, key)
+	if ea, eb, ok := p.pair(key.a, key.b); ok {
+		p.reopen(ea, eb)
+	}
+}
+
+// isTaken reports whether a pair has been played or is leased out
+func (p *Pool) isTaken(a, b *entry) bool {
+	return p.taken(keyFor(a.id, b.id))
+}
+
+func (p *Pool) taken(key pairKey) bool {
+	if _, ok := p.played[key]; ok {
+		return true
+	}
+	_, ok := p.reserved[key]
+	return ok
+}
+
+func (p *Pool) swap(i, j int) {
+	p.order[i], p.order[j] = p.order[j], p.order[i]
+	p.order[i].pos = i
+	p.order[j].pos = j
+}
+
+func (v *view) available(p *Pool, a, b *entry) bool {
+	key := keyFor(a.id, b.id)
+	if _, ok := v.seen[key]; ok {
+		return false
+	}
+	return !p.taken(key)
+}
+
+// top returns the closest available pair, dropping stale candidates on the way
+func (v *view) top() (*candidate, bool) {
+	for v.heap.Len() > 0 {
+		c := v.heap[0]
+		if c.generation == v.generation[c.a.index] {
+			return c, true
+		}
+		heap.Pop(&v.heap)
+	}
+	return nil, false
+}
+
+// unavailable moves the candidates that pointed across a pair that can no longer be
+// served. Everything between an entry and its candidate was already unavailable, so the
+// search for the next one picks up past the pair
+func (v *view) unavailable(p *Pool, a, b *entry) {
+	if v.target[a.index] == b {
+		v.refresh(p, a, b.pos+1)
+	}
+	if v.target[b.index] == a {
+		v.refresh(p, b, a.pos+1)
+	}
+}
+
+// reopen makes a pair that has become available again the candidate of whichever sits
+// higher, if it's nearer than the one it has
+func (v *view) reopen(p *Pool, a, b *entry) {
+	if !v.available(p, a, b) {
+		return
+	}
+	if b.pos < a.pos {
+		a, b = b, a
+	}
+	if t := v.target[a.index]; t == nil || t.pos > b.pos {
+		v.setTarget(a, b, p)
+	}
+}
+
+// moved updates the candidates around an entry whose place in the order changed
+func (v *view) moved(p *Pool, e *entry) {
+	v.refresh(p, e, e.pos+1)
+	for x := range v.targetedBy[e.index] {
+		v.refresh(p, x, x.pos+1)
+	}
+
+	// entries above e whose nearest available neighbour now sits past e should pick e
+	for i := e.pos - 1; i >= 0; i-- {
+		x := p.order[i]
+		if t := v.target[x.index]; t != nil && t.pos < e.pos {
+			continue
+		}
+		if v.available(p, x, e) {
+			v.setTarget(x, e, p)
+		}
+	}
+}
+
+// refresh recomputes e's candidate by scanning down the order from start for its
+// nearest available neighbour
+func (v *view) refresh(p *Pool, e *entry, start int) {
+	for i := start; i < len(p.order); i++ {
+		if f := p.order[i]; v.available(p, e, f) {
+			v.setTarget(e, f, p)
+			return
+		}
+	}
+	v.setTarget(e, nil, p)
+}
+
+// setTarget makes f e's candidate, nil for none
+func (v *view) setTarget(e, f *entry, p *Pool) {
+	if t := v.target[e.index]; t != nil {
+		delete(v.targetedBy[t.index], e)
+	}
+	v.target[e.index] = f
+	v.generation[e.index]++
+	if f == nil {
+		return
+	}
+
+	v.targetedBy[f.index][e] = struct{}{}
+	p.seq++
+	heap.Push(&v.heap, &candidate{diff: e.elo - f.elo, seq: p.seq, a: e, b: f, generation: v.generation[e.index]})
+
+	// stale candidates are dropped lazily, compact once they dominate the heap
+	if v.heap.Len() > 4*len(v.target)+16 {
+		live := v.heap[:0]
+		for _, c := range v.heap {
+			if c.generation == v.generation[c.a.index] {
+				live = append(live, c)
+			}
+		}
+		v.heap = live
+		heap.Init(&v.heap)
+	}
+}
+
+// order is by rating, highest first
+func less(a, b *entry) bool {
+	return a.elo > b.elo
+}
+
+type candidate struct {
+	diff       int
+	seq        int
+	a, b       *entry
+	generation int
+}
+
+type candidateHeap []*candidate
+
+func (h candidateHeap) Len() int { return len(h) }
+
+func (h candidateHeap) Less(i, j int) bool {
+	if h[i].diff != h[j].diff {
+		return h[i].diff < h[j].diff
+	}
+	return h[i].seq < h[j].seq
+}
+
+func (h candidateHeap) Swap(i, j int) { h[i], h[j] = h[j], h[i] }
+
+func (h *candidateHeap) Push(x any) { *h = append(*h, x.(*candidate)) }
+
+func (h *candidateHeap) Pop() any {
+	old := *h
+	c := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return c
+}
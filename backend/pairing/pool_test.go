@@ -0,0 +1,205 @@
+package pairing
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func testApplicants(rng *rand.Rand, n int) []Applicant {
+	applicants := make([]Applicant, n)
+	for i := range applicants {
+		applicants[i] = Applicant{ID: primitive.NewObjectID(), Elo: 1000 + rng.Intn(800), Deviation: 350}
+	}
+	return applicants
+}
+
+// closestAvailable is the closest pair that is neither played nor reserved, found by
+// checking every pair
+func closestAvailable(p *Pool) (int, bool) {
+	best, found := 0, false
+	for i, a := range p.order {
+		for _, b := range p.order[i+1:] {
+			if p.isTaken(a, b) {
+				continue
+			}
+			diff := a.elo - b.elo
+			if diff < 0 {
+				diff = -diff
+			}
+			if !found || diff < best {
+				best, found = diff, true
+			}
+		}
+	}
+	return best, found
+}
+
+// closestUnseenAvailable is closestAvailable leaving out the pairs in seen
+func closestUnseenAvailable(p *Pool, seen map[pairKey]bool) (int, bool) {
+	best, found := 0, false
+	for i, a := range p.order {
+		for _, b := range p.order[i+1:] {
+			if _, ok := seen[keyFor(a.id, b.id)]; ok || p.isTaken(a, b) {
+				continue
+			}
+			if diff := a.elo - b.elo; !found || diff < best {
+				best, found = diff, true
+			}
+		}
+	}
+	return best, found
+}
+
+func checkTop(t *testing.T, p *Pool, step string) {
+	t.Helper()
+
+	want, wantOK := closestAvailable(p)
+	c, ok := p.all.top()
+	if ok != wantOK {
+		t.Fatalf("%s: top found a pair = %v, brute force = %v", step, ok, wantOK)
+	}
+	if !ok {
+		return
+	}
+	if p.isTaken(c.a, c.b) {
+		t.Fatalf("%s: top returned a taken pair", step)
+	}
+	if c.diff != want {
+		t.Fatalf("%s: top returned a pair %d apart, closest available is %d apart", step, c.diff, want)
+	}
+}
+
+func TestTopMatchesBruteForce(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		applicants := testApplicants(rng, 30)
+		p := NewPool(StrategyClosest, 1+rng.Intn(2), applicants, nil)
+		now := time.Now()
+
+		leased := make(map[pairKey]bool)
+		for step := 0; step < 400; step++ {
+			name := fmt.Sprintf("seed %d step %d", seed, step)
+			switch op := rng.Intn(10); {
+			case op < 4:
+				a, b, ok := p.TakeNext("", now, now.Add(time.Minute))
+				if ok {
+					leased[keyFor(a, b)] = true
+				}
+			case op < 7:
+				for key := range leased {
+					delete(leased, key)
+					p.Record(Review{A: key.a, B: key.b, Reviewer: fmt.Sprint("reviewer", rng.Intn(3)), Judged: true})
+					break
+				}
+			case op < 8:
+				for key := range leased {
+					delete(leased, key)
+					p.Release(key.a, key.b)
+					break
+				}
+			default:
+				applicant := applicants[rng.Intn(len(applicants))]
+				p.SetRating(applicant.ID, 1000+rng.Intn(800), 200)
+			}
+			checkTop(t, p, name)
+		}
+	}
+}
+
+func TestTakeNextSkipsSeenPairs(t *testing.T) {
+	reviewers := []string{"reviewer0", "reviewer1", "reviewer2"}
+	for seed := int64(1); seed <= 20; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		applicants := testApplicants(rng, 30)
+		p := NewPool(StrategyClosest, len(reviewers), applicants, nil)
+		now := time.Now()
+
+		for step := 0; step < 400; step++ {
+			// reviewers work in runs, so each gets ahead of the others
+			reviewer := reviewers[(step/20)%len(reviewers)]
+			want, wantOK := closestUnseenAvailable(p, p.seen[reviewer])
+
+			a, b, ok := p.TakeNext(reviewer, now, now.Add(time.Minute))
+			if ok != wantOK {
+				t.Fatalf("seed %d step %d: TakeNext found a pair = %v, brute force = %v", seed, step, ok, wantOK)
+			}
+			if !ok {
+				continue
+			}
+			if _, seen := p.seen[reviewer][keyFor(a, b)]; seen {
+				t.Fatalf("seed %d step %d: served a pair the reviewer has seen", seed, step)
+			}
+			diff := p.entries[a].elo - p.entries[b].elo
+			if diff != want {
+				t.Fatalf("seed %d step %d: served a pair %d apart, closest unseen is %d apart", seed, step, diff, want)
+			}
+
+			if rng.Intn(4) == 0 {
+				p.SetRating(a, 1000+rng.Intn(800), 200)
+			}
+			if rng.Intn(8) == 0 {
+				p.Release(a, b)
+				continue
+			}
+			p.Record(Review{A: a, B: b, Reviewer: reviewer, Judged: rng.Intn(5) > 0})
+			checkTop(t, p, fmt.Sprintf("seed %d step %d", seed, step))
+		}
+	}
+}
+
+func BenchmarkPoolVote(b *testing.B) {
+	for _, n := range []int{100, 400, 1600} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(1))
+			applicants := testApplicants(rng, n)
+			p := NewPool(StrategyClosest, 1, applicants, nil)
+			now := time.Now()
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				a, c, ok := p.TakeNext("", now, now.Add(time.Minute))
+				if !ok {
+					// every pair has been played, start over
+					b.StopTimer()
+					p = NewPool(StrategyClosest, 1, applicants, nil)
+					b.StartTimer()
+					continue
+				}
+				p.SetRating(a, 1000+rng.Intn(800), 200)
+				p.SetRating(c, 1000+rng.Intn(800), 200)
+				p.Record(Review{A: a, B: c, Judged: true})
+			}
+		})
+	}
+}
+
+// BenchmarkTakeNextReviewer serves pairs to a reviewer who has judged many pairs the
+// other reviewers haven't, so the closest available pairs are mostly ones they have seen
+func BenchmarkTakeNextReviewer(b *testing.B) {
+	for _, n := range []int{100, 400, 1600} {
+		b.Run(fmt.Sprintf("n=%d", n), func(b *testing.B) {
+			rng := rand.New(rand.NewSource(1))
+			p := NewPool(StrategyClosest, 3, testApplicants(rng, n), nil)
+			now := time.Now()
+			for i := 0; i < 5*n; i++ {
+				a, c, ok := p.TakeNext("reviewer", now, now.Add(time.Minute))
+				if !ok {
+					break
+				}
+				p.Record(Review{A: a, B: c, Reviewer: "reviewer", Judged: true})
+			}
+
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				a, c, ok := p.TakeNext("reviewer", now, now.Add(time.Minute))
+				if ok {
+					p.Release(a, c)
+				}
+			}
+		})
+	}
+}
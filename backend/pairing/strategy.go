@@ -0,0 +1,21 @@
+package pairing
+
+const (
+	// StrategyClosest pairs the two closest-rated applicants that haven't met
+	StrategyClosest = "closest"
+	// StrategyInformation pairs the applicants whose vote is expected to shrink
+	// rating uncertainty the most
+	StrategyInformation = "information"
+	// StrategySwiss hands out pairs from Swiss-system rounds instead of the pool
+	StrategySwiss = "swiss"
+)
+
+// ValidStrategy reports whether name is a known strategy, empty meaning StrategyClosest
+func ValidStrategy(name string) bool {
+	switch name {
+	case "", StrategyClosest, StrategyInformation, StrategySwiss:
+		return true
+	default:
+		return false
+	}
+}
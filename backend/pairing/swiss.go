@@ -0,0 +1,104 @@
+package pairing
+
+import (
+	"math"
+	"sort"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// how many pairings the no-repeat search may try before settling for repeats
+const swissSearchBudget = 100000
+
+type SwissPlayer struct {
+	ID primitive.ObjectID
+	// Score is wins plus half of draws, plus one for each bye
+	Score  float64
+	Elo    int
+	HadBye bool
+}
+
+// SwissRounds is how many rounds a Swiss tournament of n applicants runs, enough to
+// separate a clear winner plus two rounds to settle the rest of the order
+func SwissRounds(n int) int {
+	if n < 2 {
+		return 0
+	}
+	return int(math.Ceil(math.Log2(float64(n)))) + 2
+}
+
+// PairSwissRound pairs one round Monrad style: players are ranked by score then rating,
+// so each score group sits together, and each player takes the highest ranked opponent
+// they haven't met yet, floating down into the next score group when their own is used
+// up. With an odd count the lowest ranked player who hasn't had a bye sits out.
+// Repeats are only allowed when no repeat-free round exists
+func PairSwissRound(players []SwissPlayer, played map[primitive.ObjectID][]primitive.ObjectID) ([][2]primitive.ObjectID, primitive.ObjectID) {
+	ranked := make([]SwissPlayer, len(players))
+	copy(ranked, players)
+	sort.SliceStable(ranked, func(i, j int) bool {
+		if ranked[i].Score != ranked[j].Score {
+			return ranked[i].Score > ranked[j].Score
+		}
+		if ranked[i].Elo != ranked[j].Elo {
+			return ranked[i].Elo > ranked[j].Elo
+		}
+		return ranked[i].ID.Hex() < ranked[j].ID.Hex()
+	})
+
+	bye := primitive.NilObjectID
+	if len(ranked)%2 == 1 {
+		byeIndex := len(ranked) - 1
+		for i := len(ranked) - 1; i >= 0; i-- {
+			if !ranked[i].HadBye {
+				byeIndex = i
+				break
+			}
+		}
+		bye = ranked[byeIndex].ID
+		ranked = append(ranked[:byeIndex], ranked[byeIndex+1:]...)
+	}
+
+	met := make(map[pairKey]struct{})
+	for id, opponents := range played {
+		for _, opponent := range opponents {
+			met[keyFor(id, opponent)] = struct{}{}
+		}
+	}
+
+	budget := swissSearchBudget
+	if pairs, ok := pairSwiss(ranked, met, &budget); ok {
+		return pairs, bye
+	}
+
+	// everyone has met everyone they could be paired with, allow repeats
+	budget = swissSearchBudget
+	pairs, _ := pairSwiss(ranked, map[pairKey]struct{}{}, &budget)
+	return pairs, bye
+}
+
+// pairSwiss pairs the top remaining player with the first opponent that leaves a
+// pairable remainder, backtracking when a choice strands someone
+func pairSwiss(remaining []SwissPlayer, met map[pairKey]struct{}, budget *int) ([][2]primitive.ObjectID, bool) {
+	if len(remaining) == 0 {
+		return nil, true
+	}
+
+	first := remaining[0]
+	for k := 1; k < len(remaining); k++ {
+		if _, ok := met[keyFor(first.ID, remaining[k].ID)]; ok {
+			continue
+		}
+		*budget--
+		if *budget < 0 {
+			return nil, false
+		}
+
+		rest := make([]SwissPlayer, 0, len(remaining)-2)
+		rest = append(rest, remaining[1:k]...)
+		rest = append(rest, remaining[k+1:]...)
+		if pairs, ok := pairSwiss(rest, met, budget); ok {
+			return append([][2]primitive.ObjectID{{first.ID, remaining[k].ID}}, pairs...), true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,172 @@
+package pairing
+
+import (
+	"math/rand"
+	"testing"
+
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func swissPlayers(scores ...float64) []SwissPlayer {
+	players := make([]SwissPlayer, len(scores))
+	for i, score := range scores {
+		players[i] = SwissPlayer{ID: primitive.NewObjectID(), Score: score, Elo: 1000}
+	}
+	return players
+}
+
+// checkRound makes sure every player but the bye is paired exactly once
+func checkRound(t *testing.T, players []SwissPlayer, pairs [][2]primitive.ObjectID, bye primitive.ObjectID) {
+	t.Helper()
+
+	seen := make(map[primitive.ObjectID]int)
+	for _, pair := range pairs {
+		seen[pair[0]]++
+		seen[pair[1]]++
+	}
+	if !bye.IsZero() {
+		seen[bye]++
+	}
+	for _, player := range players {
+		if seen[player.ID] != 1 {
+			t.Fatalf("player %s appears %d times", player.ID.Hex(), seen[player.ID])
+		}
+	}
+	if len(seen) != len(players) {
+		t.Fatalf("round has %d players, want %d", len(seen), len(players))
+	}
+}
+
+// repeatFreeExists reports whether the players can all be paired without a rematch
+func repeatFreeExists(ids []primitive.ObjectID, met map[pairKey]struct{}) bool {
+	if len(ids) == 0 {
+		return true
+	}
+	for k := 1; k < len(ids); k++ {
+		if _, ok := met[keyFor(ids[0], ids[k])]; ok {
+			continue
+		}
+		rest := append(append([]primitive.ObjectID{}, ids[1:k]...), ids[k+1:]...)
+		if repeatFreeExists(rest, met) {
+			return true
+		}
+	}
+	return false
+}
+
+func TestPairSwissRoundRanksByScore(t *testing.T) {
+	players := swissPlayers(0, 3, 1, 2)
+	pairs, bye := PairSwissRound(players, nil)
+
+	want := [][2]primitive.ObjectID{{players[1].ID, players[3].ID}, {players[2].ID, players[0].ID}}
+	if !bye.IsZero() {
+		t.Fatalf("even round has bye %s", bye.Hex())
+	}
+	if len(pairs) != len(want) || pairs[0] != want[0] || pairs[1] != want[1] {
+		t.Fatalf("pairs = %v, want %v", pairs, want)
+	}
+}
+
+func TestPairSwissRoundNoRepeats(t *testing.T) {
+	for seed := int64(1); seed <= 20; seed++ {
+		rng := rand.New(rand.NewSource(seed))
+		n := 2 + rng.Intn(9)
+		players := swissPlayers(make([]float64, n)...)
+		played := make(map[primitive.ObjectID][]primitive.ObjectID)
+		met := make(map[pairKey]struct{})
+
+		for round := 0; round < SwissRounds(n); round++ {
+			pairs, bye := PairSwissRound(players, played)
+			checkRound(t, players, pairs, bye)
+
+			// repeats are only allowed when every pairing of the round would have one
+			repeated := false
+			for _, pair := range pairs {
+				if _, ok := met[keyFor(pair[0], pair[1])]; ok {
+					repeated = true
+				}
+			}
+			if repeated {
+				ids := make([]primitive.ObjectID, 0, n)
+				for _, player := range players {
+					if player.ID != bye {
+						ids = append(ids, player.ID)
+					}
+				}
+				if repeatFreeExists(ids, met) {
+					t.Fatalf("seed %d round %d: repeated a pair although a repeat-free round exists", seed, round)
+				}
+			}
+
+			for _, pair := range pairs {
+				met[keyFor(pair[0], pair[1])] = struct{}{}
+				played[pair[0]] = append(played[pair[0]], pair[1])
+				played[pair[1]] = append(played[pair[1]], pair[0])
+			}
+			for i := range players {
+				switch {
+				case players[i].ID == bye:
+					players[i].Score++
+					players[i].HadBye = true
+				case rng.Intn(2) == 0:
+					players[i].Score++
+				}
+			}
+		}
+	}
+}
+
+func TestPairSwissRoundBye(t *testing.T) {
+	tests := []struct {
+		name    string
+		scores  []float64
+		hadBye  []bool
+		wantBye int
+	}{
+		{"lowest ranked sits out", []float64{2, 0, 1}, []bool{false, false, false}, 1},
+		{"lowest ranked already had one", []float64{2, 0, 1}, []bool{false, true, false}, 2},
+		{"only the leader hasn't had one", []float64{2, 0, 1, 1, 0}, []bool{false, true, true, true, true}, 0},
+		{"everyone had one", []float64{2, 0, 1}, []bool{true, true, true}, 1},
+	}
+
+	for _, tt := range tests {
+		players := swissPlayers(tt.scores...)
+		for i := range players {
+			players[i].HadBye = tt.hadBye[i]
+		}
+
+		pairs, bye := PairSwissRound(players, nil)
+		checkRound(t, players, pairs, bye)
+		if bye != players[tt.wantBye].ID {
+			t.Errorf("%s: bye went to a different player than %d", tt.name, tt.wantBye)
+		}
+	}
+}
+
+func TestPairSwissRoundAllowsUnavoidableRepeats(t *testing.T) {
+	players := swissPlayers(1, 1, 0, 0)
+	played := make(map[primitive.ObjectID][]primitive.ObjectID)
+	for _, a := range players {
+		for _, b := range players {
+			if a.ID != b.ID {
+				played[a.ID] = append(played[a.ID], b.ID)
+			}
+		}
+	}
+
+	pairs, bye := PairSwissRound(players, played)
+	checkRound(t, players, pairs, bye)
+	if len(pairs) != 2 {
+		t.Fatalf("got %d pairs, want 2", len(pairs))
+	}
+
+	// three players who have all met can't all be paired with the fourth
+	players = swissPlayers(0, 0, 0, 0)
+	played = map[primitive.ObjectID][]primitive.ObjectID{
+		players[0].ID: {players[1].ID, players[2].ID},
+		players[1].ID: {players[0].ID, players[2].ID},
+		players[2].ID: {players[0].ID, players[1].ID},
+	}
+	pairs, bye = PairSwissRound(players, played)
+	checkRound(t, players, pairs, bye)
+}
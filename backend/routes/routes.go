@@ -22,7 +22,7 @@ func SetupRoutes(router chi.Router) {
 			return url
 		}()},
 		AllowedMethods:   []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
-		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization"},
+		AllowedHeaders:   []string{"Accept", "Content-Type", "Authorization", "X-Reviewer-ID", "Idempotency-Key"},
 		AllowCredentials: true,
 		MaxAge:           300,
 	}))
@@ -31,6 +31,10 @@ func SetupRoutes(router chi.Router) {
 	projectController := controllers.NewProjectController()
 	applicantController := controllers.NewApplicantController()
 	formResponseController := controllers.NewFormResponseController()
+	matchController := controllers.NewMatchController()
+	roundController := controllers.NewRoundController()
+	leaseController := controllers.NewLeaseController()
+	fileController := controllers.NewFileController()
 	// dataController := controllers.NewDataController()
 
 	router.Route("/api", func(r chi.Router) {
@@ -38,14 +42,28 @@ func SetupRoutes(router chi.Router) {
 		r.Get("/projects", projectController.GetAll)
 		// r.Get("/data", dataController.GetAll) // TODO // when clicking "ADD NEW PROJECT" I want this to display all new projects, NOT NECESSARY FOR NOW. FOCUS ON MAKING ONE WORK
 		r.Post("/projects", projectController.Create)
+		r.Get("/projects/{id}", projectController.GetById)
+		r.Put("/projects/{id}", projectController.Update)
+		r.Delete("/projects/{id}", projectController.Delete)
+		r.Post("/projects/{id}/archive", projectController.Archive)
+		r.Get("/projects/{id}/progress", projectController.GetProgress)
+		r.Get("/projects/{id}/matches", matchController.GetByProject)
+		r.Delete("/projects/{id}/matches/last", matchController.UndoLast)
+		r.Post("/projects/{id}/replay", matchController.Replay)
+		r.Post("/projects/{id}/history/reset", projectController.ResetHistory)
+		r.Get("/projects/{id}/rounds", roundController.GetByProject)
+		r.Delete("/matches/{id}", matchController.Delete)
 
 		// r.Get("/applicants", applicantController.GetAll) // TODO
 		r.Get("/applicants", applicantController.GetById)
+		r.Post("/applicants/{id}/merge", applicantController.Merge)
 
 
 		r.Get("/getTwoForComparison", applicantController.GetTwoForComparison)
 		r.Post("/updateElo", applicantController.UpdateElo)
+		r.Delete("/leases/{id}", leaseController.Release)
 		r.Get("/rankings", applicantController.GetRankings)
+		r.Get("/files/{id}", fileController.Serve)
 		// Additional routes from server.go
 		r.Get("/background-check", aiBackgroundCheck())
 		r.Post("/formResponseListener", formResponseController.HandleFormResponse)
@@ -0,0 +1,52 @@
+//go:build ignore
+
+package main
+
+// resets a project's applicants to the initial rating and replays its recorded matches
+// go run scripts/replayRatingsScript/replayRatings.go -project <project id>
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"backend/controllers"
+	"backend/db"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func main() {
+	projectStr := flag.String("project", "", "ID of the project to replay")
+	flag.Parse()
+
+	projectID, err := primitive.ObjectIDFromHex(*projectStr)
+	if err != nil {
+		log.Fatal("Please provide a valid project ID using -project flag")
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		log.Fatal("MONGODB_URI not set in .env file")
+	}
+
+	db.ConnectMongoDB(uri)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	applicants, matches, err := controllers.ReplayProjectRatings(ctx, projectID)
+	if err != nil {
+		log.Fatalf("Replay failed: %v", err)
+	}
+
+	log.Printf("Replayed %d matches across %d applicants", matches, applicants)
+}
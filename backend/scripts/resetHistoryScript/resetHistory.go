@@ -0,0 +1,55 @@
+//go:build ignore
+
+package main
+
+// clears a project's pairing history so its pairs can be reviewed again, optionally
+// recording the reset as a new pass
+// go run scripts/resetHistoryScript/resetHistory.go -project <project id> [-new-pass] [-note "second round of reviews"]
+
+import (
+	"context"
+	"flag"
+	"log"
+	"os"
+	"time"
+
+	"backend/controllers"
+	"backend/db"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func main() {
+	projectStr := flag.String("project", "", "ID of the project to reset")
+	newPass := flag.Bool("new-pass", false, "record the reset as a new pass")
+	note := flag.String("note", "", "note stored with the new pass")
+	flag.Parse()
+
+	projectID, err := primitive.ObjectIDFromHex(*projectStr)
+	if err != nil {
+		log.Fatal("Please provide a valid project ID using -project flag")
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		log.Fatal("MONGODB_URI not set in .env file")
+	}
+
+	db.ConnectMongoDB(uri)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 60*time.Second)
+	defer cancel()
+
+	project, err := controllers.ResetProjectHistory(ctx, projectID, *newPass, "script", *note)
+	if err != nil {
+		log.Fatalf("Reset failed: %v", err)
+	}
+
+	log.Printf("Reset history for %s, now on pass %d", project.Name, project.Pass)
+}
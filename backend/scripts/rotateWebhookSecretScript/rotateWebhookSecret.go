@@ -0,0 +1,64 @@
+//go:build ignore
+
+package main
+
+// issues a new secret for signing a project's form submissions, keeping the old one valid
+// for a grace period so the form script can be updated
+// go run scripts/rotateWebhookSecretScript/rotateWebhookSecret.go -project <project id> [-grace 24h]
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"os"
+	"time"
+
+	"backend/controllers"
+	"backend/db"
+
+	"github.com/joho/godotenv"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+func main() {
+	projectStr := flag.String("project", "", "ID of the project to issue a secret for")
+	grace := flag.Duration("grace", 24*time.Hour, "how long the previous secrets keep working, 0s revokes them now")
+	flag.Parse()
+
+	projectID, err := primitive.ObjectIDFromHex(*projectStr)
+	if err != nil {
+		log.Fatal("Please provide a valid project ID using -project flag")
+	}
+	if *grace < 0 {
+		log.Fatal("-grace can't be negative")
+	}
+
+	// Load .env file
+	if err := godotenv.Load(); err != nil {
+		log.Fatal("Error loading .env file")
+	}
+
+	uri := os.Getenv("MONGODB_URI")
+	if uri == "" {
+		log.Fatal("MONGODB_URI not set in .env file")
+	}
+
+	db.ConnectMongoDB(uri)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	secret, err := controllers.RotateWebhookSecret(ctx, projectID, *grace)
+	if err != nil {
+		log.Fatalf("Rotation failed: %v", err)
+	}
+
+	if *grace > 0 {
+		log.Printf("Previous secrets keep working until %s", time.Now().Add(*grace).Format(time.RFC3339))
+	} else {
+		log.Println("Previous secrets were revoked")
+	}
+	// printed alone so it can be piped into wherever the form script keeps it
+	fmt.Println(secret)
+}
@@ -4,18 +4,22 @@ package main
 
 // uploads airtable csv for applicants to mongo DB for testing
 // cd backend
-// go run scripts/uploadTestDataScript/uploadApplicantsFromCsv.go
+// WEBHOOK_SECRET=<secret from scripts/rotateWebhookSecretScript> go run scripts/uploadTestDataScript/uploadApplicantsFromCsv.go
 
 import (
 	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
 	"encoding/base64"
 	"encoding/csv"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
@@ -25,6 +29,12 @@ import (
 const formId = "67c66785b4db64228e988097"
 
 func UploadApplicants() {
+	secret := os.Getenv("WEBHOOK_SECRET")
+	if secret == "" {
+		fmt.Println("WEBHOOK_SECRET must be set to the project's webhook secret")
+		return
+	}
+
 	// Open CSV file
 	file, err := os.Open("scripts/uploadTestDataScript/Fall 23 Rush App Responses.csv")
 	if err != nil {
@@ -149,7 +159,12 @@ func UploadApplicants() {
 		}
 
 		// Send POST request to endpoint
-		resp, err := http.Post("http://localhost:8080/api/formResponseListener", "application/json", bytes.NewBuffer(jsonData))
+		req, err := signedRequest(secret, jsonData)
+		if err != nil {
+			fmt.Printf("Error creating request: %v\n", err)
+			continue
+		}
+		resp, err := http.DefaultClient.Do(req)
 		if err != nil {
 			fmt.Printf("Error sending request: %v\n", err)
 			continue
@@ -167,6 +182,24 @@ func UploadApplicants() {
 	}
 }
 
+// signedRequest signs the body the same way the form script does
+func signedRequest(secret string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequest(http.MethodPost, "http://localhost:8080/api/formResponseListener", bytes.NewBuffer(body))
+	if err != nil {
+		return nil, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-Timestamp", timestamp)
+	req.Header.Set("X-Webhook-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return req, nil
+}
+
 func main() {
 	UploadApplicants()
 }
@@ -3,14 +3,17 @@ import { useParams, useRouter } from "next/navigation";
 import React, { useState, useEffect } from "react";
 import { Card, CardHeader, CardTitle, CardContent } from "@/components/ui/card";
 import { Separator } from "@/components/ui/separator";
+import { useUser } from "@clerk/nextjs";
 
 interface FileInfo {
-  fileID: string;
+  fileId: string;
   fileName: string;
-  fileType: string;
-  data?: string;
+  mimeType: string;
+  url?: string;
 }
 
+const apiUrl = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
+
 interface Applicant {
   id: string;
   name: string;
@@ -28,17 +31,23 @@ const CandidatesPage = () => {
   const router = useRouter();
   const params = useParams();
   const projectId = params?.id as string;
+  // the backend tracks which pairs each reviewer has seen by this ID
+  const { user, isLoaded } = useUser();
+  const reviewerHeaders: Record<string, string> = user ? { "X-Reviewer-ID": user.id } : {};
 
   const [applicants, setApplicants] = useState<Applicant[]>([]);
+  const [leaseId, setLeaseId] = useState<string | null>(null);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string | null>(null);
 
   const fetchApplicants = async () => {
     try {
       console.log("Starting fetch...");
-      const apiUrl = process.env.NEXT_PUBLIC_API_URL || "http://localhost:8080";
       console.log(apiUrl);
-      const response = await fetch(`${apiUrl}/api/getTwoForComparison`);
+      const response = await fetch(
+        `${apiUrl}/api/getTwoForComparison?project_id=${projectId}`,
+        { headers: reviewerHeaders }
+      );
 
       console.log("Content-Type:", response.headers.get("content-type"));
       if (response.status === 409) {
@@ -51,8 +60,9 @@ const CandidatesPage = () => {
 
       const data = await response.json();
       // Map _id(as stored in Mongo) to id
-      const mappedData = data.map((a: any) => ({ ...a, id: a._id }));
+      const mappedData = data.applicants.map((a: any) => ({ ...a, id: a._id }));
       setApplicants(mappedData);
+      setLeaseId(data.lease_id);
       setError(null);
     } catch (err: any) {
       console.error("Error fetching applicants:", err);
@@ -63,8 +73,10 @@ const CandidatesPage = () => {
   };
 
   useEffect(() => {
-    fetchApplicants();
-  }, [projectId]);
+    if (isLoaded) {
+      fetchApplicants();
+    }
+  }, [projectId, isLoaded, user?.id]);
 
   const handleCardSelect = async (winnerId: string, loserId: string) => {
     try {
@@ -72,13 +84,15 @@ const CandidatesPage = () => {
       const payload = {
         winnerId,
         loserId,
+        leaseId,
       };
       console.log("Sending payload:", payload); 
       
-      const response = await fetch("http://localhost:8080/api/updateElo", {
+      const response = await fetch(`${apiUrl}/api/updateElo`, {
         method: "POST",
         headers: {
           "Content-Type": "application/json",
+          ...reviewerHeaders,
         },
         body: JSON.stringify(payload),
       });
@@ -96,43 +110,44 @@ const CandidatesPage = () => {
     }
   };
 
+  const handleUndo = async () => {
+    try {
+      setLoading(true);
+      const response = await fetch(`${apiUrl}/api/projects/${projectId}/matches/last`, {
+        method: "DELETE",
+        headers: reviewerHeaders,
+      });
+      if (!response.ok) {
+        throw new Error(`Failed to undo vote: ${await response.text()}`);
+      }
+
+      // hand back the current pair, the undone one may be served next
+      if (leaseId) {
+        await fetch(`${apiUrl}/api/leases/${leaseId}`, {
+          method: "DELETE",
+          headers: reviewerHeaders,
+        });
+      }
+      await fetchApplicants();
+    } catch (err: any) {
+      console.error("Error undoing vote:", err);
+      setError(err.message);
+      setLoading(false);
+    }
+  };
+
   const handleFileClick = (
     fileInfo: FileInfo | null,
     preview: boolean = false
   ) => {
-    if (!fileInfo?.data) return;
+    if (!fileInfo?.url) return;
 
     if (preview) {
-      try {
-        // Create data URL directly for PDF preview
-        const dataUrl = `data:application/pdf;base64,${fileInfo.data}`;
-
-        // Open PDF in new window/tab
-        const newWindow = window.open("", "_blank");
-        if (newWindow) {
-          newWindow.document.write(`
-            <html>
-              <head>
-                <title>${fileInfo.fileName}</title>
-              </head>
-              <body style="margin:0;padding:0;">
-                <embed 
-                  width="100%" 
-                  height="100%" 
-                  src="${dataUrl}" 
-                  type="application/pdf"
-                />
-              </body>
-            </html>
-          `);
-        }
-      } catch (error) {
-        console.error("Error processing PDF:", error);
-        alert("Error opening PDF. Please try downloading instead.");
-      }
+      // the browser's own viewer fetches the PDF in ranges as it's scrolled
+      window.open(`${apiUrl}${fileInfo.url}`, "_blank");
     } else {
       const linkElement = document.createElement("a");
-      linkElement.href = `data:${fileInfo.fileType};base64,${fileInfo.data}`;
+      linkElement.href = `${apiUrl}${fileInfo.url}&download=1`;
       linkElement.download = fileInfo.fileName;
       document.body.appendChild(linkElement);
       linkElement.click();
@@ -189,7 +204,7 @@ const CandidatesPage = () => {
                     {applicant.image && (
                       <div>
                         <img
-                          src={`data:${applicant.image.fileType};base64,${applicant.image.data}`}
+                          src={`${apiUrl}${applicant.image.url}`}
                           alt={applicant.name}
                           className="w-full h-48 object-cover rounded-lg"
                         />
@@ -259,6 +274,12 @@ const CandidatesPage = () => {
           );
         })}
       </div>
+      <button
+        onClick={handleUndo}
+        className="mt-4 text-blue-500 hover:underline"
+      >
+        Undo last vote
+      </button>
     </div>
   );
 };